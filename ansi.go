@@ -0,0 +1,723 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// Modes of --ansi.
+const (
+	ANSIAuto     = "auto"
+	ANSIPreserve = "preserve"
+	ANSIStrip    = "strip"
+	ANSIHTML     = "html"
+)
+
+// maxSGRLen bounds the lookahead for the end of an SGR sequence. Longer
+// sequences are left to the lexer.
+const maxSGRLen = 64
+
+// ansiKindBase is the first of the kinds standing for the styles of SGR
+// sequences in the input, far enough above the kinds of the palettes to
+// leave room for more. Kinds are bytes, so there's room for 192 styles.
+const ansiKindBase syntaxhighlight.Kind = 64
+
+// ansiColor is a color set by an SGR sequence: 0 for the default color,
+// one more than the index for the 256 colors of the xterm palette, or a
+// 24-bit color with ansiTrueColor set.
+type ansiColor uint32
+
+const ansiTrueColor ansiColor = 1 << 24
+
+func ansiIndexColor(i int) ansiColor {
+	return ansiColor(i + 1)
+}
+
+func ansiRGBColor(r, g, b int) ansiColor {
+	return ansiTrueColor | ansiColor(r&0xff)<<16 | ansiColor(g&0xff)<<8 | ansiColor(b&0xff)
+}
+
+// ansiBasicColors are the RGB values of the 16 basic colors, the color
+// codes of ccat followed by white.
+var ansiBasicColors = []string{
+	"black", "darkred", "darkgreen", "brown", "darkblue", "purple", "teal", "lightgray",
+	"darkgray", "red", "green", "yellow", "blue", "fuchsia", "turquoise", "#eeeeec",
+}
+
+// RGB returns the value of c, which must not be the default color.
+func (c ansiColor) RGB() color.RGBA {
+	if c&ansiTrueColor != 0 {
+		return color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xff}
+	}
+
+	i := int(c) - 1
+	switch {
+	case i < 16:
+		rgb, _ := parseColor(ansiBasicColors[i])
+		return rgb
+	case i < 232:
+		// the 6x6x6 color cube
+		i -= 16
+		level := func(v int) uint8 {
+			if v == 0 {
+				return 0
+			}
+			return uint8(55 + v*40)
+		}
+		return color.RGBA{level(i / 36), level(i / 6 % 6), level(i % 6), 0xff}
+	}
+
+	gray := uint8(8 + (i-232)*10)
+
+	return color.RGBA{gray, gray, gray, 0xff}
+}
+
+// nearest returns the closest of the first n colors of the palette to c.
+func (c ansiColor) nearest(n int) ansiColor {
+	if c == 0 || (c&ansiTrueColor == 0 && int(c) <= n) {
+		return c
+	}
+
+	rgb := c.RGB()
+	best, dist := c, -1
+	for i := 0; i < n; i++ {
+		p := ansiIndexColor(i).RGB()
+		dr, dg, db := int(p.R)-int(rgb.R), int(p.G)-int(rgb.G), int(p.B)-int(rgb.B)
+		if d := dr*dr + dg*dg + db*db; dist < 0 || d < dist {
+			best, dist = ansiIndexColor(i), d
+		}
+	}
+
+	return best
+}
+
+// sgr returns the parameters of an SGR sequence setting c as the
+// foreground color, or the background color with bg.
+func (c ansiColor) sgr(bg bool) string {
+	base := 30
+	if bg {
+		base = 40
+	}
+
+	switch i := int(c) - 1; {
+	case c&ansiTrueColor != 0:
+		rgb := c.RGB()
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, rgb.R, rgb.G, rgb.B)
+	case i < 8:
+		return strconv.Itoa(base + i)
+	case i < 16:
+		return strconv.Itoa(base + 60 + i - 8)
+	}
+
+	return fmt.Sprintf("%d;5;%d", base+8, int(c)-1)
+}
+
+// ansiStyle is the style SGR sequences in the input give to the text
+// after them. It's kept apart from the kinds of the lexer, and travels
+// through the printers as a kind of its own. Blinking and hidden text
+// aren't kept, so that the input can't hide any of its text.
+type ansiStyle struct {
+	FG, BG    ansiColor
+	Bold      bool
+	Faint     bool
+	Italic    bool
+	Underline bool
+	Reverse   bool
+	Strike    bool
+}
+
+// apply updates s with the parameters of an SGR sequence, like "1;31" in
+// ESC[1;31m. Unknown parameters are ignored.
+func (s *ansiStyle) apply(params string) {
+	if params == "" {
+		*s = ansiStyle{}
+		return
+	}
+
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		// extended colors may be written with colons, as in 38:2::r:g:b
+		if strings.Contains(fields[i], ":") {
+			sub := strings.Split(fields[i], ":")
+			n := atoiOr(sub[0], 0)
+			if n != 38 && n != 48 {
+				s.applyCode(n)
+				continue
+			}
+			// skip the color space of 38:2:id:r:g:b
+			if len(sub) == 6 && sub[1] == "2" {
+				sub = append(sub[:2], sub[3:]...)
+			}
+			s.applyColor(n, sub[1:])
+			continue
+		}
+
+		n := atoiOr(fields[i], 0)
+		switch {
+		case n == 38 || n == 48:
+			i += s.applyColor(n, fields[i+1:])
+		default:
+			s.applyCode(n)
+		}
+	}
+}
+
+func (s *ansiStyle) applyCode(n int) {
+	switch {
+	case n == 0:
+		*s = ansiStyle{}
+	case n == 1:
+		s.Bold = true
+	case n == 2:
+		s.Faint = true
+	case n == 3:
+		s.Italic = true
+	case n == 4:
+		s.Underline = true
+	case n == 7:
+		s.Reverse = true
+	case n == 9:
+		s.Strike = true
+	case n == 21:
+		// double underline on most terminals
+		s.Underline = true
+	case n == 22:
+		s.Bold, s.Faint = false, false
+	case n == 23:
+		s.Italic = false
+	case n == 24:
+		s.Underline = false
+	case n == 27:
+		s.Reverse = false
+	case n == 29:
+		s.Strike = false
+	case n >= 30 && n <= 37:
+		s.FG = ansiIndexColor(n - 30)
+	case n == 39:
+		s.FG = 0
+	case n >= 40 && n <= 47:
+		s.BG = ansiIndexColor(n - 40)
+	case n == 49:
+		s.BG = 0
+	case n >= 90 && n <= 97:
+		s.FG = ansiIndexColor(n - 90 + 8)
+	case n >= 100 && n <= 107:
+		s.BG = ansiIndexColor(n - 100 + 8)
+	}
+}
+
+// applyColor applies the extended color 38 or 48 with the arguments in
+// args, and returns how many of them it used.
+func (s *ansiStyle) applyColor(n int, args []string) int {
+	var c ansiColor
+	used := 0
+	switch {
+	case len(args) >= 2 && args[0] == "5":
+		c, used = ansiIndexColor(atoiOr(args[1], 0)&0xff), 2
+	case len(args) >= 4 && args[0] == "2":
+		c, used = ansiRGBColor(atoiOr(args[1], 0), atoiOr(args[2], 0), atoiOr(args[3], 0)), 4
+	default:
+		return len(args)
+	}
+
+	if n == 38 {
+		s.FG = c
+	} else if n == 48 {
+		s.BG = c
+	}
+
+	return used
+}
+
+func atoiOr(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+
+	return n
+}
+
+// SGR returns the escape sequence that switches the terminal to s from
+// the default style.
+func (s ansiStyle) SGR() string {
+	params := []string{}
+	for _, a := range []struct {
+		on   bool
+		code string
+	}{
+		{s.Bold, "1"}, {s.Faint, "2"}, {s.Italic, "3"}, {s.Underline, "4"},
+		{s.Reverse, "7"}, {s.Strike, "9"},
+	} {
+		if a.on {
+			params = append(params, a.code)
+		}
+	}
+	if s.FG != 0 {
+		params = append(params, s.FG.sgr(false))
+	}
+	if s.BG != 0 {
+		params = append(params, s.BG.sgr(true))
+	}
+
+	return esc + strings.Join(params, ";") + "m"
+}
+
+// CSS returns the declarations of an inline style attribute for s.
+func (s ansiStyle) CSS() string {
+	fg, bg := s.FG, s.BG
+	if s.Reverse {
+		fg, bg = bg, fg
+	}
+
+	var decls []string
+	if fg != 0 {
+		decls = append(decls, "color: "+hexColor(fg.RGB()))
+	}
+	if bg != 0 {
+		decls = append(decls, "background-color: "+hexColor(bg.RGB()))
+	}
+	if s.Bold {
+		decls = append(decls, "font-weight: bold")
+	}
+	if s.Faint {
+		decls = append(decls, "opacity: 0.5")
+	}
+	if s.Italic {
+		decls = append(decls, "font-style: italic")
+	}
+
+	var lines []string
+	if s.Underline {
+		lines = append(lines, "underline")
+	}
+	if s.Strike {
+		lines = append(lines, "line-through")
+	}
+	if len(lines) > 0 {
+		decls = append(decls, "text-decoration: "+strings.Join(lines, " "))
+	}
+
+	return strings.Join(decls, "; ")
+}
+
+// textStyle returns s for the printers that lay out the text
+// themselves. Only the foreground color, bold and underline are kept.
+func (s ansiStyle) textStyle() textStyle {
+	ts := textStyle{Bold: s.Bold, Underline: s.Underline}
+	if s.FG != 0 {
+		ts.Color = hexColor(s.FG.RGB())
+	}
+
+	return ts
+}
+
+var (
+	ansiMu     sync.Mutex
+	ansiStyles = []ansiStyle{{}}
+	ansiKinds  = map[ansiStyle]syntaxhighlight.Kind{{}: ansiKindBase}
+)
+
+// ansiKind returns the kind standing for s, allocating it the first time
+// s is seen. As the kinds run out, new styles get the kinds of styles
+// with fewer colors, and eventually of the default style.
+func ansiKind(s ansiStyle) syntaxhighlight.Kind {
+	ansiMu.Lock()
+	defer ansiMu.Unlock()
+
+	fewer := func(n int) ansiStyle {
+		t := s
+		t.FG, t.BG = s.FG.nearest(n), s.BG.nearest(n)
+		return t
+	}
+	noBG := fewer(16)
+	noBG.BG = 0
+
+	free := int(0xff - ansiKindBase + 1)
+	for _, c := range []struct {
+		style ansiStyle
+		limit int
+	}{
+		{s, free / 2},
+		{fewer(256), free * 3 / 4},
+		{fewer(16), free},
+		{noBG, free},
+	} {
+		if k, ok := ansiKinds[c.style]; ok {
+			return k
+		}
+		if len(ansiStyles) < c.limit {
+			k := ansiKindBase + syntaxhighlight.Kind(len(ansiStyles))
+			ansiStyles = append(ansiStyles, c.style)
+			ansiKinds[c.style] = k
+			return k
+		}
+	}
+
+	return ansiKindBase
+}
+
+// kindANSIStyle returns the style k stands for if it's a kind returned by
+// ansiKind.
+func kindANSIStyle(k syntaxhighlight.Kind) (ansiStyle, bool) {
+	if k < ansiKindBase {
+		return ansiStyle{}, false
+	}
+
+	ansiMu.Lock()
+	defer ansiMu.Unlock()
+
+	i := int(k - ansiKindBase)
+	if i >= len(ansiStyles) {
+		return ansiStyle{}, false
+	}
+
+	return ansiStyles[i], true
+}
+
+// readSGR reads the rest of an SGR sequence after its ESC from br and
+// returns its parameters. If what follows isn't an SGR sequence, nothing
+// is read.
+func readSGR(br *bufio.Reader) (string, bool) {
+	// peek a byte at a time so that a sequence at the end of what's been
+	// written so far doesn't wait for more
+	for i := 0; i < maxSGRLen; i++ {
+		b, err := br.Peek(i + 1)
+		if err != nil {
+			return "", false
+		}
+
+		switch c := b[i]; {
+		case i == 0:
+			if c != '[' {
+				return "", false
+			}
+		case c == 'm':
+			params := string(b[1:i])
+			br.Discard(i + 1)
+			return params, true
+		case (c < '0' || c > '9') && c != ';' && c != ':':
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+// hasSGR reports whether b contains an SGR sequence.
+func hasSGR(b []byte) bool {
+	for {
+		i := bytes.Index(b, []byte(esc))
+		if i < 0 {
+			return false
+		}
+		b = b[i+len(esc):]
+
+		for j, c := range b {
+			if c == 'm' {
+				return true
+			}
+			if (c < '0' || c > '9') && c != ';' && c != ':' {
+				break
+			}
+			if j >= maxSGRLen {
+				break
+			}
+		}
+	}
+}
+
+// ANSI returns a TokenSource that reads text colored by SGR sequences
+// from r instead of highlighting it. The text gets the kinds of its
+// styles, and line feeds are tokens of their own so that no style spans
+// lines. Escape sequences other than SGR are left in the text.
+func ANSI(r io.Reader) TokenSource {
+	return func(w io.Writer, p syntaxhighlight.Printer) error {
+		br := bufio.NewReader(r)
+
+		var (
+			style ansiStyle
+			text  bytes.Buffer
+		)
+		flush := func() error {
+			if text.Len() == 0 {
+				return nil
+			}
+			s := text.String()
+			text.Reset()
+
+			return p.Print(w, ansiKind(style), s)
+		}
+
+		for {
+			// pass on what's been read before waiting for more
+			if br.Buffered() == 0 {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+
+			c, err := br.ReadByte()
+			if err == io.EOF {
+				return flush()
+			}
+			if err != nil {
+				return err
+			}
+
+			switch c {
+			case '\033':
+				params, ok := readSGR(br)
+				if !ok {
+					text.WriteByte(c)
+					continue
+				}
+				next := style
+				next.apply(params)
+				if next != style {
+					if err := flush(); err != nil {
+						return err
+					}
+					style = next
+				}
+			case '\n':
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := p.Print(w, syntaxhighlight.Whitespace, "\n"); err != nil {
+					return err
+				}
+			default:
+				text.WriteByte(c)
+			}
+		}
+	}
+}
+
+// sgrStripper reads from br without the SGR sequences.
+type sgrStripper struct {
+	br *bufio.Reader
+}
+
+func stripSGR(r io.Reader) io.Reader {
+	return &sgrStripper{bufio.NewReader(r)}
+}
+
+func (s *sgrStripper) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		// return what's there instead of waiting for more
+		if n > 0 && s.br.Buffered() == 0 {
+			break
+		}
+
+		c, err := s.br.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if c == '\033' {
+			if _, ok := readSGR(s.br); ok {
+				continue
+			}
+		}
+
+		p[n] = c
+		n++
+	}
+
+	return n, nil
+}
+
+// terminalReader reads the output of a program written for a terminal
+// as it would look in the terminal, a line at a time. Escape sequences
+// other than SGR and control characters other than tabs are dropped, and
+// the text after the last CR of a line replaces what's before it, like a
+// progress bar.
+type terminalReader struct {
+	br  *bufio.Reader
+	buf bytes.Buffer
+	err error
+}
+
+func terminalText(r io.Reader) io.Reader {
+	return &terminalReader{br: bufio.NewReader(r)}
+}
+
+func (t *terminalReader) Read(p []byte) (int, error) {
+	for t.buf.Len() == 0 && t.err == nil {
+		var line string
+		line, t.err = t.br.ReadString('\n')
+		t.buf.WriteString(terminalLine(line))
+	}
+	if t.buf.Len() > 0 {
+		return t.buf.Read(p)
+	}
+
+	return 0, t.err
+}
+
+// terminalSegment is the part of a line between two CRs.
+type terminalSegment struct {
+	text    bytes.Buffer
+	sgr     bytes.Buffer
+	visible bool
+}
+
+// terminalLine returns line as it would be left on the terminal. The SGR
+// sequences of the text that's overwritten are kept, since the styles
+// they set last.
+func terminalLine(line string) string {
+	nl := strings.HasSuffix(line, "\n")
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+	segs := []*terminalSegment{{}}
+	seg := segs[0]
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '\r':
+			seg = &terminalSegment{}
+			segs = append(segs, seg)
+			i++
+		case c == '\033':
+			n, sgr := escapeSequenceLen(line[i:])
+			if sgr {
+				seg.text.WriteString(line[i : i+n])
+				seg.sgr.WriteString(line[i : i+n])
+			}
+			i += n
+		case (c < 0x20 && c != '\t') || c == 0x7f:
+			i++
+		default:
+			seg.text.WriteByte(c)
+			seg.visible = true
+			i++
+		}
+	}
+
+	last := 0
+	for i, seg := range segs {
+		if seg.visible {
+			last = i
+		}
+	}
+
+	var b bytes.Buffer
+	for i, seg := range segs {
+		if i == last {
+			b.Write(seg.text.Bytes())
+		} else {
+			b.Write(seg.sgr.Bytes())
+		}
+	}
+	if nl {
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// escapeSequenceLen returns the length of the escape sequence at the
+// start of s, which is cut short by the end of s, and whether it's an
+// SGR sequence.
+func escapeSequenceLen(s string) (int, bool) {
+	if len(s) < 2 {
+		return len(s), false
+	}
+
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			c := s[i]
+			if c >= 0x40 && c <= 0x7e {
+				return i + 1, c == 'm' && !strings.ContainsAny(s[2:i], "<=>? !\"#$%&'()*+,-./")
+			}
+			if c < 0x20 || c > 0x3f {
+				return i, false
+			}
+		}
+	case ']', 'P', 'X', '^', '_':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1, false
+			}
+			if s[i] == '\033' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2, false
+			}
+		}
+	default:
+		i := 1
+		for i < len(s) && s[i] >= 0x20 && s[i] <= 0x2f {
+			i++
+		}
+		if i < len(s) && s[i] > 0x2f && s[i] <= 0x7e {
+			i++
+		}
+		return i, false
+	}
+
+	return len(s), false
+}
+
+// ANSIPrinter handles the SGR sequences of the input depending on Mode:
+// preserve and html keep the colors of the input instead of highlighting
+// it, strip removes them before highlighting, and auto preserves them
+// if there are any at the start of the input.
+//
+// With Terminal, the input is taken for the output of a program written
+// for a terminal, like a CI log, and only its text and colors are kept.
+//
+// Plain tells that Printer doesn't color its output, as when it's piped,
+// so the SGR sequences of the input are passed on to it as they are but
+// with strip, rather than lost.
+type ANSIPrinter struct {
+	Printer  CCatPrinter
+	Mode     string
+	Terminal bool
+	Plain    bool
+}
+
+func (p *ANSIPrinter) SetFile(file File) {
+	if f, ok := p.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (p *ANSIPrinter) Print(r io.Reader, w io.Writer) error {
+	if p.Terminal {
+		r = terminalText(r)
+	}
+	if p.Plain && p.Mode != ANSIStrip {
+		return p.Printer.Print(r, w)
+	}
+
+	switch p.Mode {
+	case ANSIPreserve, ANSIHTML:
+		return printTokens(p.Printer, ANSI(r), w)
+	case ANSIStrip:
+		return p.Printer.Print(stripSGR(r), w)
+	}
+
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := peekAvailable(br, sniffLen)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if hasSGR(head) {
+		return printTokens(p.Printer, ANSI(br), w)
+	}
+
+	return p.Printer.Print(br, w)
+}
+
+func (p *ANSIPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(p.Printer, src, w)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+func TestANSIStyleApply(t *testing.T) {
+	cases := []struct {
+		Params   string
+		Expected ansiStyle
+	}{
+		{"1;31", ansiStyle{Bold: true, FG: ansiIndexColor(1)}},
+		{"4;92;44", ansiStyle{Underline: true, FG: ansiIndexColor(10), BG: ansiIndexColor(4)}},
+		{"38;5;208;1", ansiStyle{FG: ansiIndexColor(208), Bold: true}},
+		{"48;2;10;20;30", ansiStyle{BG: ansiRGBColor(10, 20, 30)}},
+		{"38:2::1:2:3", ansiStyle{FG: ansiRGBColor(1, 2, 3)}},
+		{"1;3;9;22", ansiStyle{Italic: true, Strike: true}},
+		{"31;0", ansiStyle{}},
+		{"", ansiStyle{}},
+	}
+
+	for _, tc := range cases {
+		var s ansiStyle
+		s.apply(tc.Params)
+		if s != tc.Expected {
+			t.Errorf("Params: %q\n\nOutput: %+v\n\nExpected: %+v", tc.Params, s, tc.Expected)
+		}
+	}
+}
+
+func TestANSIColorPrinter(t *testing.T) {
+	var w bytes.Buffer
+	input := "a \033[1;31mred\033[0m \033[38;5;208mx\ny\033[m\n"
+
+	err := ColorPrinter{LightColorPalettes}.PrintTokens(ANSI(strings.NewReader(input)), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	// styles are reopened on the next line
+	expected := "a \033[1;31mred\033[39;49;00m \033[38;5;208mx\033[39;49;00m\n\033[38;5;208my\033[39;49;00m\n"
+	if w.String() != expected {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestANSIHtml(t *testing.T) {
+	var w bytes.Buffer
+	input := "\033[3;4;38;2;255;0;128m<b>\033[0m"
+
+	err := ANSI(strings.NewReader(input))(&w, HtmlCodePrinter{LightColorPalettes})
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expected := `<span style="color: #ff0080; font-style: italic; text-decoration: underline">&lt;b&gt;</span>`
+	if w.String() != expected {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestStripSGR(t *testing.T) {
+	input := "\033[1mbold\033[0m \033[2Jclear \033[38;5;1mred"
+	b, err := ioutil.ReadAll(stripSGR(strings.NewReader(input)))
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	// only colors are stripped, other sequences are left to -v and the
+	// control characters filter
+	if string(b) != "bold \033[2Jclear red" {
+		t.Errorf("output is wrong: %q", b)
+	}
+}
+
+func TestANSIPrinter(t *testing.T) {
+	cases := []struct {
+		Mode     string
+		Plain    bool
+		Input    string
+		Expected string
+	}{
+		{ANSIAuto, false, "plain", "\033[34mplain\033[39;49;00m"},
+		{ANSIAuto, false, "\033[32mgreen", "\033[32mgreen\033[39;49;00m"},
+		{ANSIPreserve, false, "plain", "plain"},
+		{ANSIStrip, false, "\033[32mgreen", "\033[34mgreen\033[39;49;00m"},
+		// hidden and blinking text of the input is shown as it is
+		{ANSIAuto, false, "\033[8;31mrm -rf\033[0m \033[5mx", "\033[31mrm -rf\033[39;49;00m x"},
+		{ANSIAuto, true, "\033[31mred\033[0m plain\n", "\033[31mred\033[0m plain\n"},
+		{ANSIPreserve, true, "\033[31mred\033[0m plain\n", "\033[31mred\033[0m plain\n"},
+		{ANSIStrip, true, "\033[31mred\033[0m plain\n", "red plain\n"},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		p := &ANSIPrinter{Printer: ColorPrinter{LightColorPalettes}, Mode: tc.Mode}
+		if tc.Plain {
+			p = &ANSIPrinter{Printer: PlainTextPrinter{}, Mode: tc.Mode, Plain: true}
+		}
+
+		err := p.Print(strings.NewReader(tc.Input), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		if w.String() != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %q\n\nExpected: %q", tc.Input, w.String(), tc.Expected)
+		}
+	}
+}
+
+func TestANSIKindsRunOut(t *testing.T) {
+	styles, kinds := ansiStyles, ansiKinds
+	defer func() {
+		ansiStyles, ansiKinds = styles, kinds
+	}()
+	ansiStyles = []ansiStyle{{}}
+	ansiKinds = map[ansiStyle]syntaxhighlight.Kind{{}: ansiKindBase}
+
+	for i := 0; i < 300; i++ {
+		ansiKind(ansiStyle{FG: ansiRGBColor(i, 0, 0)})
+	}
+
+	// once they run out, styles get the kind of the closest basic color
+	k := ansiKind(ansiStyle{FG: ansiRGBColor(0xcc, 0, 0)})
+	s, ok := kindANSIStyle(k)
+	if !ok || s.FG != ansiIndexColor(1) {
+		t.Errorf("style is wrong: %+v", s)
+	}
+}
+
+func TestTerminalLine(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected string
+	}{
+		{"plain\r\n", "plain\n"},
+		{" 10%\r 50%\r100%\033[K\n", "100%\n"},
+		// the styles of overwritten text last
+		{"\033[31mold\rnew\033[0m", "\033[31mnew\033[0m"},
+		{"done\r", "done"},
+		{"\033]0;title\007\033[2J\033[?25lx\a\by\n", "xy\n"},
+		{"\033[>4;1m\033[1mbold", "\033[1mbold"},
+	}
+
+	for _, tc := range cases {
+		s := terminalLine(tc.Input)
+		if s != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %q\n\nExpected: %q", tc.Input, s, tc.Expected)
+		}
+	}
+}
+
+func TestFromANSIHtml(t *testing.T) {
+	var w bytes.Buffer
+	input := "\033[1;31mFAIL\n  at main.go\033[0m\r\n"
+
+	p := &ANSIPrinter{Printer: HtmlPrinter{LightColorPalettes}, Mode: ANSIPreserve, Terminal: true}
+	err := p.Print(strings.NewReader(input), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	// spans are closed at the end of each line
+	expected := "<pre>\n" +
+		`<span style="color: #cc0000; font-weight: bold">FAIL</span>` + "\n" +
+		`<span style="color: #cc0000; font-weight: bold">  at main.go</span>` + "\n" +
+		"\n</pre>\n"
+	s := w.String()
+	if !strings.HasSuffix(s, expected) {
+		t.Errorf("output is wrong: %q", s[strings.Index(s, "<pre>"):])
+	}
+}
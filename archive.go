@@ -0,0 +1,415 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// maxArchiveLinks is how many links are followed to a member.
+const maxArchiveLinks = 8
+
+// archiveEntry is a file, a directory or a link in a tar or zip archive.
+// The target of a hard link is relative to the root of the archive, that
+// of a symbolic link to the directory of the link.
+type archiveEntry struct {
+	Name     string
+	Dir      bool
+	Link     string
+	HardLink bool
+	Size     int64
+}
+
+// archiveReader reads the entries of an archive in order. Next returns
+// io.EOF after the last one, and Open the content of the entry it
+// returned last.
+type archiveReader interface {
+	Next() (archiveEntry, error)
+	Open() (io.Reader, error)
+	Close() error
+}
+
+// openArchive opens fname as a zip archive, or as a tar archive that may
+// be compressed.
+func openArchive(fname string) (archiveReader, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if fi.Mode().IsDir() {
+		file.Close()
+		return nil, &FileError{fname, ErrIsDirectory}
+	}
+
+	var magic [4]byte
+	n, _ := io.ReadFull(file, magic[:])
+	if m := string(magic[:n]); m == "PK\x03\x04" || m == "PK\x05\x06" {
+		zr, err := zip.NewReader(file, fi.Size())
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &zipArchive{file: file, files: zr.File, i: -1}, nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	r, _, err := decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	if !matchMagic(head, 257, "ustar") && !strings.HasSuffix(strings.ToLower(decompressedName(fname)), ".tar") {
+		file.Close()
+		return nil, &FileError{fname, ErrNotArchive}
+	}
+
+	return &tarArchive{file: file, tr: tar.NewReader(br)}, nil
+}
+
+type tarArchive struct {
+	file *os.File
+	tr   *tar.Reader
+}
+
+func (t *tarArchive) Next() (archiveEntry, error) {
+	for {
+		h, err := t.tr.Next()
+		if err != nil {
+			return archiveEntry{}, err
+		}
+
+		e := archiveEntry{Name: h.Name, Size: h.Size}
+		switch h.Typeflag {
+		case tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeDir:
+			e.Dir, e.Size = true, 0
+		case tar.TypeSymlink:
+			e.Link, e.Size = h.Linkname, 0
+		case tar.TypeLink:
+			e.Link, e.HardLink, e.Size = h.Linkname, true, 0
+		}
+
+		return e, nil
+	}
+}
+
+func (t *tarArchive) Open() (io.Reader, error) {
+	return t.tr, nil
+}
+
+func (t *tarArchive) Close() error {
+	return t.file.Close()
+}
+
+type zipArchive struct {
+	file  *os.File
+	files []*zip.File
+	i     int
+
+	// rc is the content of the current entry once it's opened
+	rc io.ReadCloser
+}
+
+func (z *zipArchive) Next() (archiveEntry, error) {
+	z.closeEntry()
+
+	z.i++
+	if z.i >= len(z.files) {
+		return archiveEntry{}, io.EOF
+	}
+
+	f := z.files[z.i]
+	e := archiveEntry{Name: f.Name, Size: int64(f.UncompressedSize64)}
+	switch {
+	case f.Mode().IsDir() || strings.HasSuffix(f.Name, "/"):
+		e.Dir, e.Size = true, 0
+	case f.Mode()&os.ModeSymlink != 0:
+		// the target of a link is its content
+		r, err := z.Open()
+		if err != nil {
+			return archiveEntry{}, err
+		}
+		link, err := ioutil.ReadAll(io.LimitReader(r, 4096))
+		if err != nil {
+			return archiveEntry{}, err
+		}
+		e.Link, e.Size = string(link), 0
+	}
+
+	return e, nil
+}
+
+func (z *zipArchive) Open() (io.Reader, error) {
+	z.closeEntry()
+
+	rc, err := z.files[z.i].Open()
+	if err != nil {
+		return nil, err
+	}
+	z.rc = rc
+
+	return rc, nil
+}
+
+func (z *zipArchive) closeEntry() {
+	if z.rc != nil {
+		z.rc.Close()
+		z.rc = nil
+	}
+}
+
+func (z *zipArchive) Close() error {
+	z.closeEntry()
+
+	return z.file.Close()
+}
+
+// splitArchiveMember splits name, as in archive.tar.gz:path/in/archive.go,
+// into the archive and the name of a member. A file whose name really
+// contains a colon is taken as is.
+func splitArchiveMember(name string) (string, string, bool) {
+	if name == readFromStdin {
+		return "", "", false
+	}
+	if _, err := os.Stat(name); err == nil {
+		return "", "", false
+	}
+
+	for i := 1; i < len(name); i++ {
+		if name[i] != ':' {
+			continue
+		}
+		if fi, err := os.Stat(name[:i]); err == nil && fi.Mode().IsRegular() {
+			return name[:i], name[i+1:], true
+		}
+	}
+
+	return "", "", false
+}
+
+// memberName cleans the name of a member, so that ./src/main.go and
+// src/main.go/ are the same as src/main.go.
+func memberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// archiveMember is the content of a member, which closes its archive.
+type archiveMember struct {
+	io.Reader
+	archive archiveReader
+}
+
+func (m archiveMember) Close() error {
+	return m.archive.Close()
+}
+
+// openMember opens the member of an archive and returns its size. Links
+// are followed to their targets within the archive.
+func openMember(archive, member string) (io.ReadCloser, int64, error) {
+	name := memberName(member)
+	for i := 0; i <= maxArchiveLinks; i++ {
+		a, e, err := findMember(archive, name)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		switch {
+		case e.Dir:
+			err = &FileError{archive + ":" + member, ErrIsDirectory}
+		case e.HardLink:
+			name = memberName(e.Link)
+		case e.Link != "":
+			name = memberName(path.Join(path.Dir(name), e.Link))
+		default:
+			r, err := a.Open()
+			if err != nil {
+				a.Close()
+				return nil, 0, err
+			}
+			return archiveMember{r, a}, e.Size, nil
+		}
+		a.Close()
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return nil, 0, &FileError{archive + ":" + member, ErrTooManyLinks}
+}
+
+// findMember opens an archive and reads it up to the entry of name.
+func findMember(archive, name string) (archiveReader, archiveEntry, error) {
+	a, err := openArchive(archive)
+	if err != nil {
+		return nil, archiveEntry{}, err
+	}
+
+	for {
+		e, err := a.Next()
+		if err == io.EOF {
+			err = &FileError{archive + ":" + name, ErrNotInArchive}
+		}
+		if err != nil {
+			a.Close()
+			return nil, archiveEntry{}, err
+		}
+		if memberName(e.Name) == name {
+			return a, e, nil
+		}
+	}
+}
+
+// CCatList prints the entries of the archive fname as a tree with p. For
+// ARCHIVE:DIR, only the entries within DIR are printed.
+func CCatList(fname string, p CCatPrinter, w io.Writer) error {
+	if f, ok := p.(FilePrinter); ok {
+		f.SetFile(File{Name: fname})
+	}
+
+	archive, dir := fname, ""
+	if a, member, ok := splitArchiveMember(fname); ok {
+		archive, dir = a, memberName(member)
+	}
+	if archive == readFromStdin {
+		return &FileError{fname, ErrNotArchive}
+	}
+
+	a, err := openArchive(archive)
+	if err != nil {
+		return fileError(fname, err)
+	}
+	defer a.Close()
+
+	root := &archiveNode{archiveEntry: archiveEntry{Dir: true}}
+	for {
+		e, err := a.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fileError(fname, err)
+		}
+
+		name := memberName(e.Name)
+		if dir != "" {
+			if !strings.HasPrefix(name, dir+"/") {
+				continue
+			}
+			name = name[len(dir)+1:]
+		}
+		if name != "" {
+			root.add(strings.Split(name, "/"), e)
+		}
+	}
+
+	return printTokens(p, func(w io.Writer, cp syntaxhighlight.Printer) error {
+		var run tokenRun
+		add := func(kind syntaxhighlight.Kind, text string) error {
+			return run.Add(w, cp, kind, text)
+		}
+
+		if err := add(TreeDir, escapeControls(fname)); err != nil {
+			return err
+		}
+		if err := add(syntaxhighlight.Whitespace, "\n"); err != nil {
+			return err
+		}
+		if err := root.print(add, ""); err != nil {
+			return err
+		}
+
+		return run.Flush(w, cp)
+	}, w)
+}
+
+// archiveNode is an entry in the tree of an archive. Directories that
+// only appear in the names of their entries are nodes too.
+type archiveNode struct {
+	archiveEntry
+	children []*archiveNode
+}
+
+func (n *archiveNode) add(parts []string, e archiveEntry) {
+	var child *archiveNode
+	for _, c := range n.children {
+		if c.Name == parts[0] {
+			child = c
+			break
+		}
+	}
+	if child == nil {
+		child = &archiveNode{archiveEntry: archiveEntry{Name: parts[0], Dir: true}}
+		n.children = append(n.children, child)
+	}
+
+	if len(parts) > 1 {
+		child.add(parts[1:], e)
+		return
+	}
+	child.Dir, child.Link, child.HardLink, child.Size = e.Dir, e.Link, e.HardLink, e.Size
+}
+
+// print prints the children of n, each on a line with the branches of the
+// tree before it like tree does.
+func (n *archiveNode) print(add func(syntaxhighlight.Kind, string) error, indent string) error {
+	sort.Slice(n.children, func(i, j int) bool {
+		return n.children[i].Name < n.children[j].Name
+	})
+
+	for i, c := range n.children {
+		branch, next := "├── ", "│   "
+		if i == len(n.children)-1 {
+			branch, next = "└── ", "    "
+		}
+		if err := add(LineNumber, indent+branch); err != nil {
+			return err
+		}
+
+		var err error
+		name := escapeControls(c.Name)
+		switch {
+		case c.Dir:
+			err = add(TreeDir, name+"/")
+		case c.Link != "":
+			if err = add(TreeLink, name); err == nil {
+				err = add(LineNumber, " -> "+escapeControls(c.Link))
+			}
+		default:
+			if err = add(syntaxhighlight.Plaintext, name); err == nil {
+				err = add(LineNumber, "  "+humanSize(c.Size))
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := add(syntaxhighlight.Whitespace, "\n"); err != nil {
+			return err
+		}
+
+		if err := c.print(add, indent+next); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestArchives writes a tar.gz and a zip archive with the same files
+// to dir.
+func writeTestArchives(t *testing.T, dir string) (string, string) {
+	var tgz bytes.Buffer
+	zw := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(zw)
+	for _, h := range []struct {
+		tar.Header
+		Content string
+	}{
+		{tar.Header{Name: "./src/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		{tar.Header{Name: "./src/main.go", Typeflag: tar.TypeReg, Mode: 0644}, "package main\n"},
+		{tar.Header{Name: "./docs/README.md", Typeflag: tar.TypeReg, Mode: 0644}, "# Hi\n"},
+		{tar.Header{Name: "./README", Typeflag: tar.TypeSymlink, Linkname: "docs/README.md"}, ""},
+		{tar.Header{Name: "./copy.go", Typeflag: tar.TypeLink, Linkname: "./src/main.go"}, ""},
+		{tar.Header{Name: "./loop", Typeflag: tar.TypeSymlink, Linkname: "loop"}, ""},
+	} {
+		h.Size = int64(len(h.Content))
+		if err := tw.WriteHeader(&h.Header); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(h.Content))
+	}
+	tw.Close()
+	zw.Close()
+
+	var z bytes.Buffer
+	w := zip.NewWriter(&z)
+	for _, f := range []struct {
+		Name    string
+		Content string
+	}{
+		{"src/main.go", "package main\n"},
+		{"docs/README.md", "# Hi\n"},
+	} {
+		fw, err := w.Create(f.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(f.Content))
+	}
+	w.Close()
+
+	tarPath, zipPath := filepath.Join(dir, "release.tar.gz"), filepath.Join(dir, "bundle.zip")
+	if err := ioutil.WriteFile(tarPath, tgz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(zipPath, z.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return tarPath, zipPath
+}
+
+func TestOpenMember(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tarPath, zipPath := writeTestArchives(t, dir)
+
+	cases := []struct {
+		Name     string
+		Expected string
+		Error    string
+	}{
+		{tarPath + ":src/main.go", "package main\n", ""},
+		{tarPath + ":./docs/README.md", "# Hi\n", ""},
+		{tarPath + ":README", "# Hi\n", ""},
+		{tarPath + ":copy.go", "package main\n", ""},
+		{tarPath + ":src", "", tarPath + ":src: Is a directory"},
+		{tarPath + ":loop", "", tarPath + ":loop: Too many levels of links"},
+		{tarPath + ":nope.go", "", tarPath + ":nope.go: No such file in archive"},
+		{zipPath + ":docs/README.md", "# Hi\n", ""},
+		{zipPath + ":src/main.go", "package main\n", ""},
+	}
+
+	for _, tc := range cases {
+		var output []byte
+		r, _, err := openFile(tc.Name)
+		if err == nil {
+			output, err = ioutil.ReadAll(r)
+			r.Close()
+		}
+
+		if tc.Error != "" {
+			if err == nil || err.Error() != tc.Error {
+				t.Errorf("Name: %s\n\nerror is wrong: %v", tc.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Name: %s\n\nerror should be nil, but it's %s", tc.Name, err)
+		}
+		if string(output) != tc.Expected {
+			t.Errorf("Name: %s\n\nOutput: %q\n\nExpected: %q", tc.Name, output, tc.Expected)
+		}
+	}
+}
+
+func TestSplitArchiveMember(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tarPath, _ := writeTestArchives(t, dir)
+
+	// a file whose name has a colon in it
+	colon := filepath.Join(dir, "release.tar.gz:notes")
+	if err := ioutil.WriteFile(colon, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name    string
+		Archive string
+		Member  string
+		OK      bool
+	}{
+		{tarPath + ":src/main.go", tarPath, "src/main.go", true},
+		{tarPath + ":a:b", tarPath, "a:b", true},
+		{colon, "", "", false},
+		{tarPath, "", "", false},
+		{filepath.Join(dir, "missing.zip:main.go"), "", "", false},
+		{readFromStdin, "", "", false},
+	}
+
+	for _, tc := range cases {
+		archive, member, ok := splitArchiveMember(tc.Name)
+		if archive != tc.Archive || member != tc.Member || ok != tc.OK {
+			t.Errorf("Name: %s\n\nOutput: %s %s %v", tc.Name, archive, member, ok)
+		}
+	}
+
+	spec := parseFileSpec(tarPath + ":src/main.go:2")
+	if spec.Name != tarPath+":src/main.go" || spec.Line != 2 {
+		t.Errorf("spec is wrong: %+v", spec)
+	}
+
+	if l := detectLanguage(tarPath+":src/main.go", nil); l != "Go" {
+		t.Errorf("language is wrong: %s", l)
+	}
+}
+
+func TestCCatList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tarPath, zipPath := writeTestArchives(t, dir)
+
+	cases := []struct {
+		Name     string
+		Expected string
+	}{
+		{tarPath, tarPath + "\n" +
+			"├── README -> docs/README.md\n" +
+			"├── copy.go -> ./src/main.go\n" +
+			"├── docs/\n" +
+			"│   └── README.md  5 B\n" +
+			"├── loop -> loop\n" +
+			"└── src/\n" +
+			"    └── main.go  13 B\n"},
+		{zipPath + ":src", zipPath + ":src\n" +
+			"└── main.go  13 B\n"},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		if err := CCatList(tc.Name, PlainTextPrinter{}, &w); err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+		if w.String() != tc.Expected {
+			t.Errorf("Name: %s\n\nOutput: %q\n\nExpected: %q", tc.Name, w.String(), tc.Expected)
+		}
+	}
+
+	if err := CCatList(filepath.Join("testdata", "text.xz"), PlainTextPrinter{}, ioutil.Discard); err == nil {
+		t.Errorf("error shouldn't be nil")
+	}
+}
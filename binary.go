@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+const (
+	// sniffLen is how much of a file is looked at to tell whether it's
+	// binary.
+	sniffLen = 8192
+
+	// binaryRatio is the share of invalid UTF-8 and control bytes above
+	// which content is binary.
+	binaryRatio = 0.3
+
+	hexBytesPerLine = 16
+)
+
+// Modes of --binary.
+const (
+	BinaryNotice = "notice"
+	BinaryHex    = "hex"
+	BinaryRaw    = "raw"
+)
+
+// magicNumbers are the signatures of common binary formats. A ? in Magic
+// matches any byte. Signatures made of printable ASCII only could start a
+// text file too, so content matching them must look binary as well.
+var magicNumbers = []struct {
+	Offset int
+	Magic  string
+	Name   string
+}{
+	{0, "\x89PNG\r\n\x1a\n", "PNG image"},
+	{0, "\xff\xd8\xff", "JPEG image"},
+	{0, "GIF87a", "GIF image"},
+	{0, "GIF89a", "GIF image"},
+	{0, "BM????\x00\x00\x00\x00", "BMP image"},
+	{0, "II*\x00", "TIFF image"},
+	{0, "MM\x00*", "TIFF image"},
+	{0, "\x00\x00\x01\x00", "ICO image"},
+	{0, "RIFF????WEBP", "WebP image"},
+	{0, "RIFF????WAVE", "WAV audio"},
+	{0, "RIFF????AVI ", "AVI video"},
+	{4, "ftyp", "ISO media"},
+	{0, "ID3", "MP3 audio"},
+	{0, "OggS", "Ogg media"},
+	{0, "fLaC", "FLAC audio"},
+	{0, "%PDF-", "PDF document"},
+	{0, "PK\x03\x04", "Zip archive"},
+	{0, "PK\x05\x06", "Zip archive"},
+	{0, "\x1f\x8b", "gzip compressed data"},
+	{0, "BZh", "bzip2 compressed data"},
+	{0, "\xfd7zXZ\x00", "XZ compressed data"},
+	{0, "\x28\xb5\x2f\xfd", "Zstandard compressed data"},
+	{0, "7z\xbc\xaf\x27\x1c", "7-zip archive"},
+	{0, "Rar!\x1a\x07", "RAR archive"},
+	{257, "ustar", "tar archive"},
+	{0, "\x7fELF", "ELF executable"},
+	{0, "\xfe\xed\xfa\xce", "Mach-O executable"},
+	{0, "\xfe\xed\xfa\xcf", "Mach-O executable"},
+	{0, "\xce\xfa\xed\xfe", "Mach-O executable"},
+	{0, "\xcf\xfa\xed\xfe", "Mach-O executable"},
+	{0, "\xca\xfe\xba\xbe", "Java class or Mach-O universal binary"},
+	{0, "MZ", "DOS/Windows executable"},
+	{0, "\x00asm", "WebAssembly binary"},
+	{0, "SQLite format 3\x00", "SQLite database"},
+	{0, "wOFF", "WOFF font"},
+	{0, "wOF2", "WOFF2 font"},
+	{0, "\x00\x01\x00\x00\x00", "TrueType font"},
+	{0, "OTTO", "OpenType font"},
+}
+
+// detectBinary tells whether head, the start of some content, is binary
+// and what type of file it is.
+func detectBinary(head []byte) (string, bool) {
+	name := "data"
+	for _, m := range magicNumbers {
+		if matchMagic(head, m.Offset, m.Magic) {
+			if !isPrintableASCII(m.Magic) {
+				return m.Name, true
+			}
+			name = m.Name
+			break
+		}
+	}
+
+	if len(head) == 0 {
+		return "", false
+	}
+
+	// UTF-16 is full of NULs, but its byte order mark tells it apart
+	if name, _ := bomEncoding(head); name == "utf-16le" || name == "utf-16be" {
+		return "", false
+	}
+
+	odd := 0
+	for i := 0; i < len(head); {
+		r, size := utf8.DecodeRune(head[i:])
+		switch {
+		case r == 0:
+			return name, true
+		case r == utf8.RuneError && size == 1:
+			// a character cut off at the end of head is fine
+			if !utf8.FullRune(head[i:]) {
+				i = len(head)
+				continue
+			}
+			odd++
+		case r < 0x20 && !isTextControl(r):
+			odd++
+		}
+		i += size
+	}
+
+	if float64(odd) > binaryRatio*float64(len(head)) {
+		return name, true
+	}
+
+	return "", false
+}
+
+func matchMagic(head []byte, offset int, magic string) bool {
+	if len(head) < offset+len(magic) {
+		return false
+	}
+
+	for i := 0; i < len(magic); i++ {
+		if magic[i] != '?' && head[offset+i] != magic[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] >= 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isTextControl reports whether r is a control character that's common in
+// text: whitespace, backspace and the escape of color codes.
+func isTextControl(r rune) bool {
+	switch r {
+	case '\t', '\n', '\v', '\f', '\r', '\b', '\033':
+		return true
+	}
+
+	return false
+}
+
+// BinaryPrinter prints a notice or a hexdump in place of binary files,
+// depending on Mode, and passes text on to Printer.
+type BinaryPrinter struct {
+	Printer CCatPrinter
+	Mode    string
+
+	file File
+}
+
+func (b *BinaryPrinter) SetFile(file File) {
+	b.file = file
+
+	if f, ok := b.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (b *BinaryPrinter) Print(r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := peekAvailable(br, sniffLen)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	name, binary := detectBinary(head)
+	if !binary || b.Mode == BinaryRaw {
+		return b.Printer.Print(br, w)
+	}
+	if b.Mode == BinaryHex {
+		return printTokens(b.Printer, HexDump(br), w)
+	}
+
+	return printTokens(b.Printer, b.notice(name), w)
+}
+
+func (b *BinaryPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(b.Printer, src, w)
+}
+
+func (b *BinaryPrinter) notice(name string) TokenSource {
+	if b.file.Name != readFromStdin {
+		name = fmt.Sprintf("%s, %s", name, humanSize(b.file.Size))
+		if b.file.Format != "" {
+			name += ", " + b.file.Format
+		}
+	}
+
+	return func(w io.Writer, p syntaxhighlight.Printer) error {
+		text := fmt.Sprintf("Binary file (%s) not shown, use --binary=hex to dump it", name)
+		if err := p.Print(w, Warning, text); err != nil {
+			return err
+		}
+
+		return p.Print(w, syntaxhighlight.Whitespace, "\n")
+	}
+}
+
+// HexDump returns the tokens of a hexdump of r in the format of
+// hexdump -C, with the offsets, the bytes and their ASCII column in the
+// kinds of their classes.
+func HexDump(r io.Reader) TokenSource {
+	return func(w io.Writer, p syntaxhighlight.Printer) error {
+		var run tokenRun
+		add := func(kind syntaxhighlight.Kind, text string) error {
+			return run.Add(w, p, kind, text)
+		}
+
+		buf := make([]byte, hexBytesPerLine)
+		offset := 0
+		for {
+			n, err := io.ReadFull(r, buf)
+			if n > 0 {
+				if err := hexDumpLine(add, offset, buf[:n]); err != nil {
+					return err
+				}
+				offset += n
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		// the offset of the end, like hexdump -C
+		if offset > 0 {
+			if err := add(HexOffset, fmt.Sprintf("%08x", offset)); err != nil {
+				return err
+			}
+			if err := add(syntaxhighlight.Whitespace, "\n"); err != nil {
+				return err
+			}
+		}
+
+		return run.Flush(w, p)
+	}
+}
+
+func hexDumpLine(add func(syntaxhighlight.Kind, string) error, offset int, line []byte) error {
+	if err := add(HexOffset, fmt.Sprintf("%08x", offset)); err != nil {
+		return err
+	}
+	if err := add(syntaxhighlight.Whitespace, " "); err != nil {
+		return err
+	}
+
+	for i := 0; i < hexBytesPerLine; i++ {
+		sep := " "
+		if i == hexBytesPerLine/2 {
+			sep = "  "
+		}
+		if err := add(syntaxhighlight.Whitespace, sep); err != nil {
+			return err
+		}
+
+		if i >= len(line) {
+			if err := add(syntaxhighlight.Whitespace, "  "); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := add(byteKind(line[i]), fmt.Sprintf("%02x", line[i])); err != nil {
+			return err
+		}
+	}
+
+	if err := add(syntaxhighlight.Whitespace, "  "); err != nil {
+		return err
+	}
+	if err := add(HexOffset, "|"); err != nil {
+		return err
+	}
+	for _, c := range line {
+		text := "."
+		if c >= 0x20 && c < 0x7f {
+			text = string(c)
+		}
+		if err := add(byteKind(c), text); err != nil {
+			return err
+		}
+	}
+	if err := add(HexOffset, "|"); err != nil {
+		return err
+	}
+
+	return add(syntaxhighlight.Whitespace, "\n")
+}
+
+func byteKind(c byte) syntaxhighlight.Kind {
+	switch {
+	case c == 0:
+		return HexNull
+	case c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r':
+		return HexWhitespace
+	case c < 0x20 || c == 0x7f:
+		return HexControl
+	case c >= 0x80:
+		return HexHighBit
+	}
+
+	return HexPrintable
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDetectBinary(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Name   string
+		Binary bool
+	}{
+		{"\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "PNG image", true},
+		{"\x7fELF\x02\x01\x01", "ELF executable", true},
+		// a signature of printable ASCII isn't enough
+		{"MZ is a text file\n", "", false},
+		{"MZ\x90\x00\x03\x00\x00\x00", "DOS/Windows executable", true},
+		{"text\x00with a NUL", "data", true},
+		{"caf\xe9 cr\xe8me br\xfbl\xe9e\n", "", false},
+		// a character cut off by the end of the sniffed bytes
+		{"日本\xe8\xaa", "", false},
+		{"\x1b[31mred\x1b[0m\n", "", false},
+		// UTF-16 with a byte order mark
+		{"\xff\xfeh\x00i\x00\n\x00", "", false},
+		{"\xfe\xff\x00h\x00i\x00\n", "", false},
+		{"", "", false},
+	}
+
+	for _, tc := range cases {
+		name, binary := detectBinary([]byte(tc.Input))
+		if name != tc.Name || binary != tc.Binary {
+			t.Errorf("Input: %q\n\nOutput: %q %v\n\nExpected: %q %v", tc.Input, name, binary, tc.Name, tc.Binary)
+		}
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	var w bytes.Buffer
+	err := PlainTextPrinter{}.PrintTokens(HexDump(strings.NewReader("hello, world!\n\x00\x01\xff")), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expected := "00000000  68 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 01  |hello, world!...|\n" +
+		"00000010  ff                                                |.|\n" +
+		"00000011\n"
+	if w.String() != expected {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestBinaryPrinter(t *testing.T) {
+	cases := []struct {
+		Mode     string
+		Input    string
+		Expected string
+	}{
+		{BinaryNotice, "plain text\n", "plain text\n"},
+		{BinaryNotice, "\x7fELF\x00", "Binary file (ELF executable) not shown, use --binary=hex to dump it\n"},
+		{BinaryHex, "\x7fELF\x00", "00000000  7f 45 4c 46 00                                    |.ELF.|\n00000005\n"},
+		{BinaryRaw, "\x7fELF\x00", "\x7fELF\x00"},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		p := &BinaryPrinter{Printer: PlainTextPrinter{}, Mode: tc.Mode}
+		p.SetFile(File{Name: readFromStdin})
+
+		err := p.Print(strings.NewReader(tc.Input), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		if w.String() != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %q\n\nExpected: %q", tc.Input, w.String(), tc.Expected)
+		}
+	}
+}
+
+func TestBinaryPrinterNotice(t *testing.T) {
+	cases := []struct {
+		File     File
+		Expected string
+	}{
+		{File{Name: readFromStdin}, "Binary file (ELF executable) not shown, use --binary=hex to dump it\n"},
+		{File{Name: "app", Size: 2048}, "Binary file (ELF executable, 2.0 KB) not shown, use --binary=hex to dump it\n"},
+		{File{Name: "app.gz", Size: 512, Format: "gzip"}, "Binary file (ELF executable, 512 B, gzip) not shown, use --binary=hex to dump it\n"},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		p := &BinaryPrinter{Printer: PlainTextPrinter{}, Mode: BinaryNotice}
+		p.SetFile(tc.File)
+
+		err := p.Print(strings.NewReader("\x7fELF\x00"), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		if w.String() != tc.Expected {
+			t.Errorf("File: %v\n\nOutput: %q\n\nExpected: %q", tc.File.Name, w.String(), tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// CatOptions are the line options of cat.
+type CatOptions struct {
+	Number          bool
+	NumberNonblank  bool
+	SqueezeBlank    bool
+	ShowEnds        bool
+	ShowTabs        bool
+	ShowNonprinting bool
+}
+
+func (o CatOptions) Enabled() bool {
+	return o != CatOptions{}
+}
+
+// CatPrinter applies CatOptions to the tokens before they reach Printer.
+// Line numbers and markers are separate tokens of the LineNumber and
+// Marker kinds, so they're never part of the highlighted text.
+// The state of the lines is kept across files like cat does.
+type CatPrinter struct {
+	Printer CCatPrinter
+	Options CatOptions
+
+	line     int
+	midLine  bool
+	blankRun int
+}
+
+func (c *CatPrinter) SetFile(file File) {
+	if f, ok := c.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (c *CatPrinter) Print(r io.Reader, w io.Writer) error {
+	return c.PrintTokens(Lex(r), w)
+}
+
+func (c *CatPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(c.Printer, func(w io.Writer, p syntaxhighlight.Printer) error {
+		return src(w, catCodePrinter{c, p})
+	}, w)
+}
+
+type catCodePrinter struct {
+	*CatPrinter
+	p syntaxhighlight.Printer
+}
+
+func (c catCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	for len(tokText) > 0 {
+		text := tokText
+		i := strings.IndexByte(tokText, '\n')
+		if i >= 0 {
+			text = tokText[:i]
+		}
+		tokText = tokText[len(text):]
+
+		if len(text) > 0 {
+			if !c.midLine {
+				c.midLine = true
+				c.blankRun = 0
+				if err := c.number(w); err != nil {
+					return err
+				}
+			}
+
+			if err := c.printText(w, kind, text); err != nil {
+				return err
+			}
+		}
+
+		if i >= 0 {
+			tokText = tokText[1:]
+			if err := c.endLine(w); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c catCodePrinter) endLine(w io.Writer) error {
+	if !c.midLine {
+		c.blankRun++
+		if c.Options.SqueezeBlank && c.blankRun > 1 {
+			return nil
+		}
+
+		// -b doesn't number blank lines
+		if !c.Options.NumberNonblank {
+			if err := c.number(w); err != nil {
+				return err
+			}
+		}
+	}
+	c.midLine = false
+
+	if c.Options.ShowEnds {
+		if err := c.p.Print(w, Marker, "$"); err != nil {
+			return err
+		}
+	}
+
+	return c.p.Print(w, syntaxhighlight.Whitespace, "\n")
+}
+
+func (c catCodePrinter) number(w io.Writer) error {
+	if !c.Options.Number && !c.Options.NumberNonblank {
+		return nil
+	}
+
+	c.line++
+	if err := c.p.Print(w, LineNumber, fmt.Sprintf("%6d", c.line)); err != nil {
+		return err
+	}
+
+	return c.p.Print(w, syntaxhighlight.Whitespace, "\t")
+}
+
+// printText prints text with the characters made visible by -T and -v
+// replaced by markers.
+func (c catCodePrinter) printText(w io.Writer, kind syntaxhighlight.Kind, text string) error {
+	if !c.Options.ShowTabs && !c.Options.ShowNonprinting {
+		return c.p.Print(w, kind, text)
+	}
+
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		m := c.marker(r, size, text[i])
+		if len(m) == 0 {
+			i += size
+			continue
+		}
+
+		if start < i {
+			if err := c.p.Print(w, kind, text[start:i]); err != nil {
+				return err
+			}
+		}
+		if err := c.p.Print(w, Marker, m); err != nil {
+			return err
+		}
+
+		i += size
+		start = i
+	}
+
+	if start < len(text) {
+		return c.p.Print(w, kind, text[start:])
+	}
+
+	return nil
+}
+
+// marker returns the ^ or M- notation of r, or "" if r is shown as is.
+// Unlike cat, -v leaves printable UTF-8 alone and only uses M- notation
+// for invalid bytes and C1 control characters.
+func (c catCodePrinter) marker(r rune, size int, b byte) string {
+	if r == '\t' {
+		if c.Options.ShowTabs {
+			return "^I"
+		}
+		return ""
+	}
+
+	if !c.Options.ShowNonprinting {
+		return ""
+	}
+
+	switch {
+	case r == utf8.RuneError && size == 1:
+		return "M-" + caretNotation(rune(b-0x80))
+	case r < 0x20 || r == 0x7f:
+		return caretNotation(r)
+	case r >= 0x80 && r < 0xa0:
+		return "M-" + caretNotation(r-0x80)
+	}
+
+	return ""
+}
+
+func caretNotation(r rune) string {
+	switch {
+	case r < 0x20:
+		return "^" + string(r+'@')
+	case r == 0x7f:
+		return "^?"
+	}
+
+	return string(r)
+}
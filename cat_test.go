@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCatPrinter(t *testing.T) {
+	input := "a\tb\n\n\n\nc\x01\xff\r\nd"
+	cases := []struct {
+		Options CatOptions
+		Output  string
+	}{
+		{
+			Options: CatOptions{Number: true},
+			Output:  "     1\ta\tb\n     2\t\n     3\t\n     4\t\n     5\tc\x01\xff\r\n     6\td",
+		},
+		{
+			Options: CatOptions{NumberNonblank: true, SqueezeBlank: true},
+			Output:  "     1\ta\tb\n\n     2\tc\x01\xff\r\n     3\td",
+		},
+		{
+			Options: CatOptions{ShowEnds: true, ShowTabs: true, ShowNonprinting: true},
+			Output:  "a^Ib$\n$\n$\n$\nc^AM-^?^M$\nd",
+		},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		p := &CatPrinter{Printer: PlainTextPrinter{}, Options: tc.Options}
+		err := p.Print(bytes.NewBufferString(input), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		if w.String() != tc.Output {
+			t.Errorf("Options: %+v\n\nOutput: %q\n\nExpected: %q", tc.Options, w.String(), tc.Output)
+		}
+	}
+}
+
+func TestCatPrinterColors(t *testing.T) {
+	var w bytes.Buffer
+	p := &CatPrinter{
+		Printer: ColorPrinter{LightColorPalettes},
+		Options: CatOptions{Number: true, ShowEnds: true},
+	}
+	err := p.Print(bytes.NewBufferString("x\n"), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	// the line number and the marker are outside of the span of x
+	expect := "\033[30;01m     1\033[39;49;00m\t\033[34mx\033[39;49;00m\033[35m$\033[39;49;00m\n"
+	if w.String() != expect {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestCatPrinterAcrossFiles(t *testing.T) {
+	var w bytes.Buffer
+	p := &CatPrinter{Printer: PlainTextPrinter{}, Options: CatOptions{Number: true}}
+	for _, s := range []string{"a\n", "b\n"} {
+		err := p.Print(bytes.NewBufferString(s), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+	}
+
+	if w.String() != "     1\ta\n     2\tb\n" {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
@@ -1,14 +1,11 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"io"
-	"io/ioutil"
 	"os"
 	"syscall"
 
-	"fmt"
-
 	"github.com/mattn/go-isatty"
 )
 
@@ -16,6 +13,47 @@ type CCatPrinter interface {
 	Print(r io.Reader, w io.Writer) error
 }
 
+// TokenPrinter is implemented by printers that can print a stream of
+// tokens, like those of ccat render --from-tokens, rather than source code
+// they lex themselves.
+type TokenPrinter interface {
+	PrintTokens(src TokenSource, w io.Writer) error
+}
+
+// printTokens prints the tokens of src with p, or just their text if p
+// isn't a TokenPrinter.
+func printTokens(p CCatPrinter, src TokenSource, w io.Writer) error {
+	if t, ok := p.(TokenPrinter); ok {
+		return t.PrintTokens(src, w)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(src(pw, PlainTextCodePrinter{}))
+	}()
+	err := p.Print(pr, w)
+	pr.Close()
+
+	return err
+}
+
+// FilePrinter is implemented by printers that decorate the output with
+// information about the file being printed. CCat calls SetFile once it's
+// opened each file, before printing it.
+type FilePrinter interface {
+	SetFile(file File)
+}
+
+// File is a file being printed: its name, and what's found opening it,
+// which is its size, the format it's compressed in and the start of its
+// content. The size of standard input is unknown and 0.
+type File struct {
+	Name   string
+	Size   int64
+	Format string
+	Head   []byte
+}
+
 type AutoColorPrinter struct {
 	ColorPalettes ColorPalettes
 }
@@ -28,12 +66,24 @@ func (a AutoColorPrinter) Print(r io.Reader, w io.Writer) error {
 	}
 }
 
+func (a AutoColorPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	if isatty.IsTerminal(uintptr(syscall.Stdout)) {
+		return ColorPrinter{a.ColorPalettes}.PrintTokens(src, w)
+	} else {
+		return PlainTextPrinter{}.PrintTokens(src, w)
+	}
+}
+
 type ColorPrinter struct {
 	ColorPalettes ColorPalettes
 }
 
 func (c ColorPrinter) Print(r io.Reader, w io.Writer) error {
-	return CPrint(r, w, c.ColorPalettes)
+	return c.PrintTokens(Lex(r), w)
+}
+
+func (c ColorPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return CPrint(src, w, c.ColorPalettes)
 }
 
 type PlainTextPrinter struct {
@@ -44,43 +94,168 @@ func (p PlainTextPrinter) Print(r io.Reader, w io.Writer) error {
 	return err
 }
 
+func (p PlainTextPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return src(w, PlainTextCodePrinter{})
+}
+
 type HtmlPrinter struct {
 	ColorPalettes ColorPalettes
 }
 
 func (c HtmlPrinter) Print(r io.Reader, w io.Writer) error {
-	return HtmlPrint(r, w, c.ColorPalettes)
+	return c.PrintTokens(Lex(r), w)
+}
+
+func (c HtmlPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return HtmlPrint(src, w, c.ColorPalettes)
+}
+
+type RtfPrinter struct {
+	ColorPalettes ColorPalettes
+}
+
+func (p RtfPrinter) Print(r io.Reader, w io.Writer) error {
+	return p.PrintTokens(Lex(r), w)
+}
+
+func (p RtfPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return RtfPrint(src, w, p.ColorPalettes)
+}
+
+type LatexPrinter struct {
+	ColorPalettes ColorPalettes
+}
+
+func (p LatexPrinter) Print(r io.Reader, w io.Writer) error {
+	return p.PrintTokens(Lex(r), w)
+}
+
+func (p LatexPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return LatexPrint(src, w, p.ColorPalettes)
+}
+
+type JsonTokensPrinter struct {
+}
+
+func (p JsonTokensPrinter) Print(r io.Reader, w io.Writer) error {
+	return p.PrintTokens(Lex(r), w)
 }
 
+func (p JsonTokensPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return JsonTokensPrint(src, w)
+}
+
+type SvgPrinter struct {
+	ColorPalettes ColorPalettes
+	BG            string
+	Background    string
+	Frame         bool
+	Padding       int
+}
+
+func (s SvgPrinter) Print(r io.Reader, w io.Writer) error {
+	return s.PrintTokens(Lex(r), w)
+}
+
+func (s SvgPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return SvgPrint(src, w, s)
+}
+
+type PngPrinter struct {
+	ColorPalettes ColorPalettes
+	BG            string
+	Background    string
+	LineNumbers   bool
+	Padding       int
+	Scale         int
+}
+
+func (p PngPrinter) Print(r io.Reader, w io.Writer) error {
+	return p.PrintTokens(Lex(r), w)
+}
+
+func (p PngPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return PngPrint(src, w, p)
+}
+
+// CCat prints fname with p. Errors opening or reading fname are returned
+// as *FileErrors.
 func CCat(fname string, p CCatPrinter, w io.Writer) error {
-	var r io.Reader
+	return ccat(fname, func(file File, r io.Reader) error {
+		if f, ok := p.(FilePrinter); ok {
+			f.SetFile(file)
+		}
 
-	if fname == readFromStdin {
-		b, err := ioutil.ReadAll(os.Stdin)
-		if err != nil {
-			return err
+		return p.Print(r, w)
+	})
+}
+
+// CCatTokens renders the token stream in fname, which is in the format
+// written by JsonTokensPrint, with p.
+func CCatTokens(fname string, p CCatPrinter, w io.Writer) error {
+	return ccat(fname, func(file File, r io.Reader) error {
+		if f, ok := p.(FilePrinter); ok {
+			f.SetFile(file)
 		}
 
-		r = bytes.NewReader(b)
+		return printTokens(p, JsonTokens(r), w)
+	})
+}
+
+// ccat opens and decompresses fname, and prints it with print.
+func ccat(fname string, print func(file File, r io.Reader) error) error {
+	var r io.Reader
+	file := File{Name: fname}
+
+	if fname == readFromStdin {
+		r = os.Stdin
 	} else {
-		file, err := os.Open(fname)
+		f, size, err := openFile(fname)
 		if err != nil {
-			return err
+			return fileError(fname, err)
 		}
 
-		defer file.Close()
+		defer f.Close()
 
-		fi, err := file.Stat()
-		if err != nil {
-			return err
-		}
+		r, file.Size = f, size
+	}
 
-		if fi.Mode().IsDir() {
-			return fmt.Errorf("%s is a directory", file.Name())
-		}
+	r, format, err := decompress(r)
+	if err != nil {
+		return fileError(fname, err)
+	}
+	file.Format = format
+
+	br := bufio.NewReaderSize(r, sniffLen)
+	if file.Head, err = peekAvailable(br, sniffLen); err != nil && err != io.EOF {
+		return fileError(fname, err)
+	}
+
+	return print(file, fileReader{br, fname})
+}
+
+// openFile opens fname, or the member of an archive if fname is
+// ARCHIVE:MEMBER, and returns its size.
+func openFile(fname string) (io.ReadCloser, int64, error) {
+	if archive, member, ok := splitArchiveMember(fname); ok {
+		return openMember(archive, member)
+	}
+
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
 
-		r = file
+	if fi.Mode().IsDir() {
+		file.Close()
+		return nil, 0, &FileError{fname, ErrIsDirectory}
 	}
 
-	return p.Print(r, w)
+	return file, fi.Size(), nil
 }
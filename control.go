@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// States of the escape sequences ControlPrinter recognizes.
+const (
+	ctrlGround = iota
+	ctrlEscape
+	ctrlCSI
+	ctrlString
+	ctrlStringEscape
+)
+
+// ControlPrinter keeps control characters and escape sequences in the
+// input from reaching the terminal, where they could move the cursor,
+// hide text, set the title or write to the clipboard. Control characters
+// other than tabs and line feeds are printed in ^ notation, C1 control
+// characters in M- notation like cat -v, and the whole of an escape
+// sequence is printed in the Warning kind up to its final character.
+//
+// A CR is only passed on before an LF, where it can't overwrite the line.
+type ControlPrinter struct {
+	Printer CCatPrinter
+}
+
+func (p *ControlPrinter) SetFile(file File) {
+	if f, ok := p.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (p *ControlPrinter) Print(r io.Reader, w io.Writer) error {
+	return p.PrintTokens(Lex(r), w)
+}
+
+func (p *ControlPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(p.Printer, func(w io.Writer, cp syntaxhighlight.Printer) error {
+		ctrl := &controlCodePrinter{p: cp}
+		if err := src(w, ctrl); err != nil {
+			return err
+		}
+		if err := ctrl.flushCR(w, false); err != nil {
+			return err
+		}
+
+		return ctrl.run.Flush(w, ctrl.p)
+	}, w)
+}
+
+type controlCodePrinter struct {
+	p syntaxhighlight.Printer
+
+	state int
+	run   tokenRun
+
+	// cr is set after a CR until it's known whether an LF follows
+	cr     bool
+	crKind syntaxhighlight.Kind
+}
+
+func (p *controlCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	for len(tokText) > 0 {
+		r, size := utf8.DecodeRuneInString(tokText)
+		text := tokText[:size]
+		tokText = tokText[size:]
+
+		if err := p.flushCR(w, r == '\n'); err != nil {
+			return err
+		}
+
+		var err error
+		switch {
+		case r == '\n':
+			// sequences don't span lines, a broken one ends at the LF
+			p.state = ctrlGround
+			err = p.add(w, kind, text)
+		case r == '\r' && p.state == ctrlGround:
+			p.cr, p.crKind = true, kind
+		case p.state == ctrlGround:
+			err = p.printGround(w, kind, r, text)
+		default:
+			err = p.printSequence(w, kind, r, text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printGround prints a character outside of escape sequences.
+func (p *controlCodePrinter) printGround(w io.Writer, kind syntaxhighlight.Kind, r rune, text string) error {
+	switch {
+	case r == '\033':
+		p.state = ctrlEscape
+	case r == 0x9b:
+		p.state = ctrlCSI
+	case r == 0x90 || r == 0x98 || r == 0x9d || r == 0x9e || r == 0x9f:
+		p.state = ctrlString
+	case r == '\t' || !isControl(r, len(text)):
+		return p.add(w, kind, text)
+	}
+
+	return p.add(w, Warning, controlNotation(r))
+}
+
+// printSequence prints a character of an escape sequence. A character
+// that can't be part of the sequence ends it and is printed on its own.
+func (p *controlCodePrinter) printSequence(w io.Writer, kind syntaxhighlight.Kind, r rune, text string) error {
+	switch p.state {
+	case ctrlEscape:
+		switch {
+		case r == '[':
+			p.state = ctrlCSI
+		case r == ']' || r == 'P' || r == 'X' || r == '^' || r == '_':
+			p.state = ctrlString
+		case r >= 0x20 && r <= 0x2f:
+			// intermediate bytes before the final one
+		case r >= 0x30 && r <= 0x7e:
+			p.state = ctrlGround
+		default:
+			p.state = ctrlGround
+			return p.printGround(w, kind, r, text)
+		}
+	case ctrlCSI:
+		switch {
+		case r >= 0x20 && r <= 0x3f:
+		case r >= 0x40 && r <= 0x7e:
+			p.state = ctrlGround
+		default:
+			p.state = ctrlGround
+			return p.printGround(w, kind, r, text)
+		}
+	case ctrlString:
+		// strings end with a BEL or a string terminator
+		switch r {
+		case '\a', 0x9c:
+			p.state = ctrlGround
+		case '\033':
+			p.state = ctrlStringEscape
+		}
+	case ctrlStringEscape:
+		if r != '\\' {
+			p.state = ctrlEscape
+			return p.printSequence(w, kind, r, text)
+		}
+		p.state = ctrlGround
+	}
+
+	if isControl(r, len(text)) {
+		text = controlNotation(r)
+	}
+
+	return p.add(w, Warning, text)
+}
+
+// flushCR prints a pending CR as is if an LF follows it, or in ^
+// notation otherwise.
+func (p *controlCodePrinter) flushCR(w io.Writer, lf bool) error {
+	if !p.cr {
+		return nil
+	}
+	p.cr = false
+
+	if lf {
+		return p.add(w, p.crKind, "\r")
+	}
+
+	return p.add(w, Warning, controlNotation('\r'))
+}
+
+func (p *controlCodePrinter) add(w io.Writer, kind syntaxhighlight.Kind, text string) error {
+	return p.run.Add(w, p.p, kind, text)
+}
+
+// isControl reports whether r, which was decoded from size bytes, is a
+// C0 or C1 control character. Invalid bytes aren't, since terminals
+// expecting UTF-8 don't interpret them.
+func isControl(r rune, size int) bool {
+	if r == utf8.RuneError && size == 1 {
+		return false
+	}
+
+	return r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0)
+}
+
+// controlNotation returns r in ^ or M- notation if it's a control
+// character, or as is otherwise.
+func controlNotation(r rune) string {
+	if r >= 0x80 && r < 0xa0 {
+		return "M-" + caretNotation(r-0x80)
+	}
+
+	return caretNotation(r)
+}
+
+// escapeControls replaces the control characters in s with their ^ or M-
+// notation, for text like file names that ccat prints itself.
+func escapeControls(s string) string {
+	var buf bytes.Buffer
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if r == '\t' || !isControl(r, size) {
+			buf.WriteString(s[:size])
+		} else {
+			buf.WriteString(controlNotation(r))
+		}
+		s = s[size:]
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestControlPrinter(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected string
+	}{
+		{"plain\ttext\r\n", "plain\ttext\r\n"},
+		{"\x1b[31mred\x1b[0m\n", "^[[31mred^[[0m\n"},
+		// the title is set by an OSC ending with a BEL or ST
+		{"\x1b]0;pwned\x07x\x1b]52;c;ZWNobw==\x1b\\y\n", "^[]0;pwned^Gx^[]52;c;ZWNobw==^[\\y\n"},
+		{"rm -rf /\rls\n", "rm -rf /^Mls\n"},
+		{"bell\x07 del\x7f back\x08\n", "bell^G del^? back^H\n"},
+		{"c1 \u009b2J csi\n", "c1 M-^[2J csi\n"},
+		// an unterminated sequence ends at the line
+		{"\x1b]0;title\nnext\n", "^[]0;title\nnext\n"},
+		{"caf\xe9\n", "caf\xe9\n"},
+		{"end\r", "end^M"},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		p := &ControlPrinter{Printer: PlainTextPrinter{}}
+
+		err := p.Print(bytes.NewBufferString(tc.Input), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		if w.String() != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %q\n\nExpected: %q", tc.Input, w.String(), tc.Expected)
+		}
+	}
+}
+
+func TestControlPrinterKind(t *testing.T) {
+	var w bytes.Buffer
+	p := &ControlPrinter{Printer: ColorPrinter{LightColorPalettes}}
+
+	err := p.Print(bytes.NewBufferString("\x1b[2Jx"), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expected := "\033[01m\033[31m^[[2J\033[39;49;00m\033[36mx\033[39;49;00m"
+	if w.String() != expected {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestEscapeControls(t *testing.T) {
+	s := escapeControls("evil\x1b]0;x\x07.txt")
+	if s != "evil^[]0;x^G.txt" {
+		t.Errorf("output is wrong: %q", s)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// compressionFormats are the compressed formats ccat decompresses. Input
+// is recognized by its magic numbers, and Exts map the extensions of a
+// compressed file to those of the file inside, like .tgz to .tar.
+var compressionFormats = []struct {
+	Name      string
+	Magic     []string
+	Exts      map[string]string
+	NewReader func(r io.Reader) (io.Reader, error)
+}{
+	{
+		Name:  "gzip",
+		Magic: []string{"\x1f\x8b\x08"},
+		Exts:  map[string]string{".gz": "", ".tgz": ".tar"},
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		Name:  "bzip2",
+		Magic: []string{"BZh1", "BZh2", "BZh3", "BZh4", "BZh5", "BZh6", "BZh7", "BZh8", "BZh9"},
+		Exts:  map[string]string{".bz2": "", ".tbz": ".tar", ".tbz2": ".tar"},
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		},
+	},
+	{
+		Name:      "xz",
+		Magic:     []string{xzMagic},
+		Exts:      map[string]string{".xz": "", ".txz": ".tar"},
+		NewReader: newXZReader,
+	},
+	{
+		Name:      "zstd",
+		Magic:     []string{"\x28\xb5\x2f\xfd"},
+		Exts:      map[string]string{".zst": "", ".zstd": "", ".tzst": ".tar"},
+		NewReader: newZstdReader,
+	},
+}
+
+// decompress returns a reader of the decompressed content of r and the
+// name of its format if r is compressed, or of r as is otherwise.
+func decompress(r io.Reader) (io.Reader, string, error) {
+	br := bufio.NewReader(r)
+	head, err := peekAvailable(br, len(xzMagic))
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+
+	if i := compressionFormat(head); i >= 0 {
+		f := compressionFormats[i]
+		zr, err := f.NewReader(br)
+		if err != nil {
+			return nil, "", err
+		}
+		return zr, f.Name, nil
+	}
+
+	return br, "", nil
+}
+
+// compressionFormat returns the index in compressionFormats of the format
+// whose magic number head starts with, or -1.
+func compressionFormat(head []byte) int {
+	for i, f := range compressionFormats {
+		for _, m := range f.Magic {
+			if bytes.HasPrefix(head, []byte(m)) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// decompressedName returns the name of the file compressed in fname,
+// telling by its extension: config.yaml for config.yaml.gz.
+func decompressedName(fname string) string {
+	ext := filepath.Ext(fname)
+	for _, f := range compressionFormats {
+		if inner, ok := f.Exts[strings.ToLower(ext)]; ok {
+			return strings.TrimSuffix(fname, ext) + inner
+		}
+	}
+
+	return fname
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// The files in testdata were compressed with the xz, bzip2 and zstd
+// tools from decompressText and decompressRandom.
+
+// decompressText is over 128 KB, so that zstd splits it into blocks that
+// take tables over from each other.
+func decompressText() []byte {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon"}
+
+	var buf bytes.Buffer
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&buf, "%d: the quick brown fox jumps over the lazy %s\n", i, words[rnd.Intn(len(words))])
+	}
+
+	return buf.Bytes()
+}
+
+// decompressRandom doesn't compress, so xz and zstd store it as is.
+func decompressRandom() []byte {
+	b := make([]byte, 1024)
+	rand.New(rand.NewSource(1)).Read(b)
+
+	return b
+}
+
+func TestDecompress(t *testing.T) {
+	text, random := decompressText(), decompressRandom()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(text)
+	zw.Close()
+
+	randomZst, err := ioutil.ReadFile(filepath.Join("testdata", "random.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two frames with a skippable frame between them
+	frames := append(append(append([]byte{}, randomZst...), "\x50\x2a\x4d\x18\x02\x00\x00\x00hi"...), randomZst...)
+
+	cases := []struct {
+		File     string
+		Input    []byte
+		Format   string
+		Expected []byte
+	}{
+		{Input: []byte("plain text\n"), Expected: []byte("plain text\n")},
+		{Input: []byte("BZh is not bzip2\n"), Expected: []byte("BZh is not bzip2\n")},
+		{Input: []byte{}, Expected: []byte{}},
+		{Input: gz.Bytes(), Format: "gzip", Expected: text},
+		{File: "text.bz2", Format: "bzip2", Expected: text},
+		{File: "text.xz", Format: "xz", Expected: text},
+		{File: "random.xz", Format: "xz", Expected: random},
+		{File: "text.zst", Format: "zstd", Expected: text},
+		{File: "text-ultra.zst", Format: "zstd", Expected: text},
+		{File: "random.zst", Format: "zstd", Expected: random},
+		{Input: frames, Format: "zstd", Expected: append(append([]byte{}, random...), random...)},
+	}
+
+	for _, tc := range cases {
+		input := tc.Input
+		if tc.File != "" {
+			if input, err = ioutil.ReadFile(filepath.Join("testdata", tc.File)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r, format, err := decompress(bytes.NewReader(input))
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+			continue
+		}
+		output, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("File: %s\n\nerror should be nil, but it's %s", tc.File, err)
+		}
+		if format != tc.Format || !bytes.Equal(output, tc.Expected) {
+			t.Errorf("File: %s\n\nOutput: %s %d bytes\n\nExpected: %s %d bytes", tc.File, format, len(output), tc.Format, len(tc.Expected))
+		}
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	for _, file := range []string{"text.xz", "text.zst", "random.xz", "random.zst"} {
+		input, err := ioutil.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a flipped bit in the middle and a cut off end
+		flipped := append([]byte{}, input...)
+		flipped[len(flipped)/2] ^= 0x10
+		for _, input := range [][]byte{flipped, input[:len(input)-5]} {
+			r, _, err := decompress(bytes.NewReader(input))
+			if err == nil {
+				_, err = ioutil.ReadAll(r)
+			}
+			if err == nil {
+				t.Errorf("File: %s\n\nerror shouldn't be nil", file)
+			}
+		}
+	}
+}
+
+func TestDecompressedName(t *testing.T) {
+	for fname, expected := range map[string]string{
+		"config.yaml.gz": "config.yaml",
+		"app.log.1.GZ":   "app.log.1",
+		"src.tgz":        "src.tar",
+		"data.json.zst":  "data.json",
+		"notes.xz":       "notes",
+		"main.go":        "main.go",
+	} {
+		if name := decompressedName(fname); name != expected {
+			t.Errorf("name of %s is wrong: %s", fname, name)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+const defaultTerminalWidth = 80
+
+// Decorations are the components of --style.
+type Decorations struct {
+	Header  bool
+	Grid    bool
+	Numbers bool
+	Changes bool
+	Rule    bool
+}
+
+func (d Decorations) Enabled() bool {
+	return d != Decorations{}
+}
+
+// Gutter reports whether lines are prefixed by a gutter.
+func (d Decorations) Gutter() bool {
+	return d.Numbers || d.Changes
+}
+
+// GutterWidth is the width of the gutter up to its bar.
+func (d Decorations) GutterWidth() int {
+	n := 0
+	if d.Numbers {
+		n += gutterNumberWidth + 1
+	}
+	if d.Changes {
+		n += 2
+	}
+
+	return n
+}
+
+// Frame reports whether anything is drawn around the lines of a file.
+func (d Decorations) Frame() bool {
+	return d.Header || d.Grid || d.Rule
+}
+
+// parseDecorations parses a comma separated list of --style components.
+func parseDecorations(s string) (Decorations, error) {
+	var d Decorations
+	for _, c := range strings.Split(s, ",") {
+		switch strings.TrimSpace(c) {
+		case "", "plain":
+		case "full":
+			d = Decorations{true, true, true, true, true}
+		case "header":
+			d.Header = true
+		case "grid":
+			d.Grid = true
+		case "numbers":
+			d.Numbers = true
+		case "changes":
+			d.Changes = true
+		case "rule":
+			d.Rule = true
+		default:
+			return d, fmt.Errorf("unknown style component: %s", c)
+		}
+	}
+
+	return d, nil
+}
+
+// terminalWidth returns the width of the terminal on standard output,
+// or of $COLUMNS if it's not a terminal.
+func terminalWidth() int {
+	if w, _, ok := terminalSize(uintptr(syscall.Stdout)); ok {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+
+	return defaultTerminalWidth
+}
+
+// FramePrinter draws the header, grid and rule decorations around each
+// file. It wraps the output printer directly so that the lines it adds
+// aren't numbered or selected like the lines of the file. The rule is
+// drawn above every file it prints but the first.
+type FramePrinter struct {
+	Printer     CCatPrinter
+	Decorations Decorations
+	Width       int
+
+	file  File
+	files int
+}
+
+func (f *FramePrinter) SetFile(file File) {
+	f.file = file
+
+	if p, ok := f.Printer.(FilePrinter); ok {
+		p.SetFile(file)
+	}
+}
+
+func (f *FramePrinter) Print(r io.Reader, w io.Writer) error {
+	return f.PrintTokens(Lex(r), w)
+}
+
+func (f *FramePrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	f.files++
+
+	return printTokens(f.Printer, func(w io.Writer, p syntaxhighlight.Printer) error {
+		fp := &framePrinter{FramePrinter: f, p: p, lineStart: true}
+		if err := fp.printTop(w); err != nil {
+			return err
+		}
+		if err := src(w, fp); err != nil {
+			return err
+		}
+
+		return fp.printBottom(w)
+	}, w)
+}
+
+// framePrinter passes the tokens of a file on and tracks whether the
+// last one ended its line.
+type framePrinter struct {
+	*FramePrinter
+	p         syntaxhighlight.Printer
+	lineStart bool
+}
+
+func (f *framePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	if len(tokText) == 0 {
+		return nil
+	}
+	f.lineStart = strings.HasSuffix(tokText, "\n")
+
+	return f.p.Print(w, kind, tokText)
+}
+
+func (f *framePrinter) printTop(w io.Writer) error {
+	d := f.Decorations
+	if d.Rule && !d.Grid && f.files > 1 {
+		if err := f.printRule(w, ""); err != nil {
+			return err
+		}
+	}
+
+	if d.Grid {
+		if err := f.printRule(w, "┬"); err != nil {
+			return err
+		}
+	}
+
+	if !d.Header {
+		return nil
+	}
+
+	name, info := f.header()
+	if err := f.printHeader(w, "File: ", name); err != nil {
+		return err
+	}
+	if len(info) > 0 {
+		if err := f.printHeader(w, "", info); err != nil {
+			return err
+		}
+	}
+
+	if d.Grid {
+		return f.printRule(w, "┼")
+	}
+
+	return nil
+}
+
+func (f *framePrinter) printBottom(w io.Writer) error {
+	if !f.lineStart {
+		if err := f.p.Print(w, syntaxhighlight.Whitespace, "\n"); err != nil {
+			return err
+		}
+	}
+
+	if f.Decorations.Grid {
+		return f.printRule(w, "┴")
+	}
+
+	return nil
+}
+
+// printRule prints a horizontal line across the width, which is joined
+// to the bar of the gutter by joint.
+func (f *framePrinter) printRule(w io.Writer, joint string) error {
+	g := 0
+	if f.Decorations.Gutter() && joint != "" {
+		g = f.Decorations.GutterWidth()
+	} else {
+		joint = ""
+	}
+
+	rest := f.Width - g - stringWidth(joint)
+	if rest < 0 {
+		rest = 0
+	}
+
+	line := strings.Repeat("─", g) + joint + strings.Repeat("─", rest)
+	if err := f.p.Print(w, LineNumber, line); err != nil {
+		return err
+	}
+
+	return f.p.Print(w, syntaxhighlight.Whitespace, "\n")
+}
+
+func (f *framePrinter) printHeader(w io.Writer, label, text string) error {
+	if f.Decorations.Grid && f.Decorations.Gutter() {
+		if err := f.p.Print(w, syntaxhighlight.Whitespace, strings.Repeat(" ", f.Decorations.GutterWidth())); err != nil {
+			return err
+		}
+		if err := f.p.Print(w, LineNumber, "│"); err != nil {
+			return err
+		}
+		if err := f.p.Print(w, syntaxhighlight.Whitespace, " "); err != nil {
+			return err
+		}
+	}
+
+	if len(label) > 0 {
+		if err := f.p.Print(w, Header, label); err != nil {
+			return err
+		}
+	}
+	if err := f.p.Print(w, Header, text); err != nil {
+		return err
+	}
+
+	return f.p.Print(w, syntaxhighlight.Whitespace, "\n")
+}
+
+// header returns the name of the file and a line with its size, its
+// compression and its language.
+func (f *framePrinter) header() (string, string) {
+	if f.file.Name == readFromStdin {
+		return "STDIN", ""
+	}
+
+	s := "Size: " + humanSize(f.file.Size)
+	if f.file.Format != "" {
+		s += " (" + f.file.Format + ")"
+	}
+	info := []string{s}
+	if l := detectLanguage(f.file.Name, f.file.Head); l != "" {
+		info = append(info, "Language: "+l)
+	}
+
+	return escapeControls(f.file.Name), strings.Join(info, "   ")
+}
+
+func humanSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	size, unit := float64(n)/1024, "KB"
+	for _, u := range []string{"MB", "GB"} {
+		if size < 1024 {
+			break
+		}
+		size, unit = size/1024, u
+	}
+
+	return fmt.Sprintf("%.1f %s", size, unit)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDecorations(t *testing.T) {
+	d, err := parseDecorations("header,grid, numbers")
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+	if d != (Decorations{Header: true, Grid: true, Numbers: true}) {
+		t.Errorf("decorations are wrong: %+v", d)
+	}
+
+	_, err = parseDecorations("header,frame")
+	if err == nil || err.Error() != "unknown style component: frame" {
+		t.Errorf("error is wrong: %v", err)
+	}
+}
+
+func TestFramePrinter(t *testing.T) {
+	cases := []struct {
+		Decorations Decorations
+		Expected    string
+	}{
+		{
+			Decorations: Decorations{Header: true, Grid: true},
+			Expected:    "──────────\nFile: STDIN\n──────────\na\nb\n──────────\n",
+		},
+		{
+			Decorations: Decorations{Header: true, Grid: true, Numbers: true},
+			Expected:    "─────┬────\n     │ File: STDIN\n─────┼────\n   1 │ a\n   2 │ b\n─────┴────\n",
+		},
+		{
+			Decorations: Decorations{Rule: true},
+			Expected:    "a\nb\n──────────\na\nb\n",
+		},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		var p CCatPrinter = &FramePrinter{Printer: PlainTextPrinter{}, Decorations: tc.Decorations, Width: 10}
+		if tc.Decorations.Gutter() {
+			p = &GutterPrinter{Printer: p, Numbers: tc.Decorations.Numbers}
+		}
+
+		files := 1
+		if tc.Decorations.Rule {
+			files = 2
+		}
+		for i := 0; i < files; i++ {
+			p.(FilePrinter).SetFile(File{Name: readFromStdin})
+			err := p.Print(bytes.NewBufferString("a\nb"), &w)
+			if err != nil {
+				t.Errorf("error should be nil, but it's %s", err)
+			}
+		}
+
+		if w.String() != tc.Expected {
+			t.Errorf("Decorations: %+v\n\nOutput: %q\n\nExpected: %q", tc.Decorations, w.String(), tc.Expected)
+		}
+	}
+}
+
+func TestFramePrinterFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "build.gz")
+	var z bytes.Buffer
+	zw := gzip.NewWriter(&z)
+	zw.Write([]byte("#!/bin/sh\nmake\n"))
+	zw.Close()
+	if err := ioutil.WriteFile(fname, z.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the header tells what's found opening the file, and the file that
+	// can't be opened isn't counted for the rule
+	var w bytes.Buffer
+	p := &FramePrinter{Printer: PlainTextPrinter{}, Decorations: Decorations{Header: true, Rule: true}, Width: 10}
+	if err := CCat(filepath.Join(dir, "missing"), p, &w); err == nil {
+		t.Errorf("printing a missing file should fail")
+	}
+	for i := 0; i < 2; i++ {
+		if err := CCat(fname, p, &w); err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+	}
+
+	header := "File: " + fname + "\nSize: " + humanSize(int64(z.Len())) + " (gzip)   Language: Shell\n#!/bin/sh\nmake\n"
+	expected := header + "──────────\n" + header
+	if w.String() != expected {
+		t.Errorf("Output: %q\n\nExpected: %q", w.String(), expected)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Head     string
+		Expected string
+	}{
+		{"main.go", "", "Go"},
+		{"lib/App.JS", "", "JavaScript"},
+		{"Makefile", "", "Makefile"},
+		{"script", "#!/usr/bin/env python3\nprint(1)", "Python"},
+		{"script", "#!/bin/sh -e\n", "Shell"},
+		{"notes", "hello", ""},
+		{"config.yaml.gz", "", "YAML"},
+		{"script.xz", "#!/bin/bash\n", "Shell"},
+	}
+
+	for _, tc := range cases {
+		l := detectLanguage(tc.Name, []byte(tc.Head))
+		if l != tc.Expected {
+			t.Errorf("Name: %s\n\nOutput: %s\n\nExpected: %s", tc.Name, l, tc.Expected)
+		}
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	for n, expected := range map[int64]string{
+		0:       "0 B",
+		1023:    "1023 B",
+		1536:    "1.5 KB",
+		5 << 20: "5.0 MB",
+		3 << 40: "3072.0 GB",
+	} {
+		if s := humanSize(n); s != expected {
+			t.Errorf("size of %d is wrong: %s", n, s)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+// lineChange is how a line differs from the previous version of a file.
+type lineChange int
+
+const (
+	lineUnchanged lineChange = iota
+	lineAdded
+	lineModified
+	// lines were removed right before or after the line
+	lineRemovedAbove
+	lineRemovedBelow
+)
+
+// diffLines returns the changes of the lines of b compared to a, by
+// line number starting at 1.
+func diffLines(a, b []string) map[int]lineChange {
+	changes := make(map[int]lineChange)
+
+	// skip the common prefix and suffix, which are most of a file
+	// usually, before running the diff
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+
+	// group the operations into hunks of removed and inserted lines
+	y := prefix
+	for i := 0; i < len(ops); {
+		if ops[i] == diffEqual {
+			i++
+			y++
+			continue
+		}
+
+		removed, inserted := 0, 0
+		for ; i < len(ops) && ops[i] != diffEqual; i++ {
+			if ops[i] == diffDelete {
+				removed++
+			} else {
+				inserted++
+			}
+		}
+
+		switch {
+		case inserted == 0 && y < len(b):
+			changes[y+1] = lineRemovedAbove
+		case inserted == 0 && y > 0:
+			changes[y] = lineRemovedBelow
+		default:
+			change := lineAdded
+			if removed > 0 {
+				change = lineModified
+			}
+			for j := 0; j < inserted; j++ {
+				changes[y+j+1] = change
+			}
+		}
+		y += inserted
+	}
+
+	return changes
+}
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffMaxEdits is how many lines myersDiff adds and removes at most
+// before it gives up on the shortest list of operations.
+const diffMaxEdits = 1000
+
+// myersDiff returns the shortest list of operations that turn a into b
+// with the algorithm of "An O(ND) Difference Algorithm and Its
+// Variations" by Eugene W. Myers. Past diffMaxEdits, all of a is deleted
+// and all of b inserted instead.
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > diffMaxEdits {
+		max = diffMaxEdits
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// only the diagonals a step can reach are kept for each step, from
+	// -d to d
+	var trace [][]int
+
+	d := 0
+loop:
+	for ; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break loop
+			}
+		}
+	}
+
+	if d > max {
+		ops := make([]diffOp, 0, n+m)
+		for i := 0; i < n; i++ {
+			ops = append(ops, diffDelete)
+		}
+		for i := 0; i < m; i++ {
+			ops = append(ops, diffInsert)
+		}
+		return ops
+	}
+
+	// walk the trace backwards to recover the operations
+	var ops []diffOp
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffEqual)
+			x--
+			y--
+		}
+
+		if x == prevX {
+			ops = append(ops, diffInsert)
+		} else {
+			ops = append(ops, diffDelete)
+		}
+		x, y = prevX, prevY
+	}
+	for ; x > 0; x-- {
+		ops = append(ops, diffEqual)
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
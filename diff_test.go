@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		Old, New string
+		Changes  map[int]lineChange
+	}{
+		{
+			Old:     "a b c",
+			New:     "a b c",
+			Changes: map[int]lineChange{},
+		},
+		{
+			Old:     "a b c",
+			New:     "a x b c y",
+			Changes: map[int]lineChange{2: lineAdded, 5: lineAdded},
+		},
+		{
+			Old:     "a b c d",
+			New:     "a x d",
+			Changes: map[int]lineChange{2: lineModified},
+		},
+		{
+			Old:     "a b c d",
+			New:     "a d",
+			Changes: map[int]lineChange{2: lineRemovedAbove},
+		},
+		{
+			Old:     "a b c d",
+			New:     "a b",
+			Changes: map[int]lineChange{2: lineRemovedBelow},
+		},
+		{
+			Old:     "",
+			New:     "a b",
+			Changes: map[int]lineChange{1: lineAdded, 2: lineAdded},
+		},
+	}
+
+	for _, tc := range cases {
+		actual := diffLines(strings.Fields(tc.Old), strings.Fields(tc.New))
+		if !reflect.DeepEqual(actual, tc.Changes) {
+			t.Errorf("Old: %q\n\nNew: %q\n\nChanges: %v\n\nExpected: %v", tc.Old, tc.New, actual, tc.Changes)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	const n = 8000
+
+	var old, rewritten, edited []string
+	for i := 0; i < n; i++ {
+		old = append(old, fmt.Sprintf("old %d", i))
+		rewritten = append(rewritten, fmt.Sprintf("new %d", i))
+		edited = append(edited, fmt.Sprintf("old %d", i))
+	}
+	edited[10] = "new 10"
+	edited[n-10] = "new"
+
+	// a file that's rewritten is all modified past the limit of edits
+	changes := diffLines(old, rewritten)
+	if len(changes) != n {
+		t.Errorf("%d lines are changed, expected %d", len(changes), n)
+	}
+	for line, change := range changes {
+		if change != lineModified {
+			t.Errorf("line %d is %v, expected modified", line, change)
+			break
+		}
+	}
+
+	// and a few edits in a long file are still found
+	changes = diffLines(old, edited)
+	expected := map[int]lineChange{11: lineModified, n - 9: lineModified}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Changes: %v\n\nExpected: %v", changes, expected)
+	}
+}
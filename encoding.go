@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// EncodingAuto is the --encoding that detects the encoding of each file.
+const EncodingAuto = "auto"
+
+// encodings are the character encodings ccat transcodes to UTF-8. Decode
+// reads a character from br, returning U+FFFD for an invalid sequence.
+var encodings = []struct {
+	Name    string
+	Aliases []string
+	BOM     string
+	Decode  func(br *bufio.Reader) (rune, error)
+}{
+	{
+		Name:    "utf-8",
+		Aliases: []string{"utf8"},
+		BOM:     "\xef\xbb\xbf",
+		Decode: func(br *bufio.Reader) (rune, error) {
+			r, _, err := br.ReadRune()
+			return r, err
+		},
+	},
+	{
+		Name:    "utf-16le",
+		Aliases: []string{"utf16le"},
+		BOM:     "\xff\xfe",
+		Decode: func(br *bufio.Reader) (rune, error) {
+			return decodeUTF16(br, func(b []byte) rune { return rune(b[0]) | rune(b[1])<<8 })
+		},
+	},
+	{
+		Name:    "utf-16be",
+		Aliases: []string{"utf16be", "utf-16", "utf16"},
+		BOM:     "\xfe\xff",
+		Decode: func(br *bufio.Reader) (rune, error) {
+			return decodeUTF16(br, func(b []byte) rune { return rune(b[0])<<8 | rune(b[1]) })
+		},
+	},
+	{
+		Name:    "latin1",
+		Aliases: []string{"latin-1", "iso-8859-1", "iso8859-1"},
+		Decode: func(br *bufio.Reader) (rune, error) {
+			b, err := br.ReadByte()
+			return rune(b), err
+		},
+	},
+	{
+		Name:    "windows-1252",
+		Aliases: []string{"cp1252"},
+		Decode:  decodeWindows1252,
+	},
+	{
+		Name:    "shift_jis",
+		Aliases: []string{"shift-jis", "sjis", "cp932", "windows-31j"},
+		Decode:  decodeShiftJIS,
+	},
+}
+
+// encodingName returns the name of the encoding named or aliased name, or
+// "" if there's none.
+func encodingName(name string) string {
+	name = strings.ToLower(name)
+	for _, e := range encodings {
+		if e.Name == name {
+			return e.Name
+		}
+		for _, a := range e.Aliases {
+			if a == name {
+				return e.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// bomEncoding returns the encoding whose byte order mark head starts with
+// and the length of the mark.
+func bomEncoding(head []byte) (string, int) {
+	for _, e := range encodings {
+		if e.BOM != "" && bytes.HasPrefix(head, []byte(e.BOM)) {
+			return e.Name, len(e.BOM)
+		}
+	}
+
+	return "", 0
+}
+
+// detectEncoding tells the encoding of head, the start of some content,
+// by its byte order mark or else by the sequences it's made of: UTF-8 if
+// they're mostly valid, Shift_JIS if they are in it and Windows-1252,
+// which covers Latin-1, otherwise. It returns "" for content that looks
+// binary.
+func detectEncoding(head []byte) string {
+	if name, _ := bomEncoding(head); name != "" {
+		return name
+	}
+
+	if utf8.Valid(head[:completeRunes(head)]) {
+		return "utf-8"
+	}
+	if hasBinaryBytes(head) {
+		return ""
+	}
+	if isMostlyUTF8(head[:completeRunes(head)]) {
+		return "utf-8"
+	}
+	if isShiftJIS(head) {
+		return "shift_jis"
+	}
+
+	return "windows-1252"
+}
+
+// hasBinaryBytes tells whether head has the magic number of a binary
+// format, a NUL or too many control characters to be text in any
+// encoding.
+func hasBinaryBytes(head []byte) bool {
+	for _, m := range magicNumbers {
+		if !isPrintableASCII(m.Magic) && matchMagic(head, m.Offset, m.Magic) {
+			return true
+		}
+	}
+
+	ctrl := 0
+	for _, b := range head {
+		switch {
+		case b == 0:
+			return true
+		case b < 0x20 && !isTextControl(rune(b)):
+			ctrl++
+		}
+	}
+
+	return float64(ctrl) > binaryRatio*float64(len(head))
+}
+
+// isMostlyUTF8 tells whether head has more multibyte characters of UTF-8
+// than bytes that aren't valid in it, like a UTF-8 file with a stray byte
+// of another encoding would.
+func isMostlyUTF8(head []byte) bool {
+	multibyte, invalid := 0, 0
+	for len(head) > 0 {
+		r, n := utf8.DecodeRune(head)
+		if r == utf8.RuneError && n == 1 {
+			invalid++
+		} else if n > 1 {
+			multibyte++
+		}
+		head = head[n:]
+	}
+
+	return multibyte > invalid
+}
+
+// isShiftJIS tells whether head is all valid Shift_JIS, with at least as
+// many two byte characters as half-width katakana, which the accented
+// letters of Latin-1 text would be taken for.
+func isShiftJIS(head []byte) bool {
+	double, kana := 0, 0
+	for i := 0; i < len(head); i++ {
+		b := head[i]
+		switch {
+		case b < 0x80:
+		case b >= 0xa1 && b <= 0xdf:
+			kana++
+		case isSJISLead(b):
+			// a character cut off at the end of head is fine
+			if i+1 == len(head) {
+				continue
+			}
+			if sjisChar(b, head[i+1]) == utf8.RuneError {
+				return false
+			}
+			double++
+			i++
+		default:
+			return false
+		}
+	}
+
+	return double > 0 && double >= kana
+}
+
+// EncodingPrinter transcodes files in the encoding Encoding, or the one
+// they're detected to be in with EncodingAuto, to UTF-8 for Printer. Byte
+// order marks are dropped.
+type EncodingPrinter struct {
+	Printer  CCatPrinter
+	Encoding string
+}
+
+func (p *EncodingPrinter) SetFile(file File) {
+	if f, ok := p.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (p *EncodingPrinter) Print(r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := peekAvailable(br, sniffLen)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	name := encodingName(p.Encoding)
+	if p.Encoding == EncodingAuto {
+		name = detectEncoding(head)
+		// ASCII is the same in all of them, so the encoding of a file that
+		// starts with it is told by the first bytes that aren't
+		if isASCII(head) {
+			return p.Printer.Print(&decodeReader{br: br, decode: decodeLater()}, w)
+		}
+	}
+	if bom, n := bomEncoding(head); bom == name {
+		br.Discard(n)
+	}
+
+	if decode := encodingDecoder(name); decode != nil {
+		return p.Printer.Print(&decodeReader{br: br, decode: decode}, w)
+	}
+
+	return p.Printer.Print(br, w)
+}
+
+func (p *EncodingPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(p.Printer, src, w)
+}
+
+// encodingDecoder returns the Decode of the encoding name, or nil.
+func encodingDecoder(name string) func(br *bufio.Reader) (rune, error) {
+	for _, e := range encodings {
+		if e.Name == name {
+			return e.Decode
+		}
+	}
+
+	return nil
+}
+
+// decodeLater returns a Decode that reads ASCII as it is and tells the
+// encoding at the first byte that isn't, by what's read from there on.
+// Text that turns out to be binary is read as UTF-8.
+func decodeLater() func(br *bufio.Reader) (rune, error) {
+	var decode func(br *bufio.Reader) (rune, error)
+
+	return func(br *bufio.Reader) (rune, error) {
+		if decode != nil {
+			return decode(br)
+		}
+
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if b[0] < utf8.RuneSelf {
+			br.Discard(1)
+			return rune(b[0]), nil
+		}
+
+		head, _ := peekAvailable(br, sniffLen)
+		if completeRunes(head) == 0 {
+			// the first character is cut off at the end of what's read
+			head, _ = br.Peek(utf8.UTFMax)
+		}
+		decode = encodingDecoder("utf-8")
+		if d := encodingDecoder(detectEncoding(head)); d != nil {
+			decode = d
+		}
+
+		return decode(br)
+	}
+}
+
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decodeReader reads the characters decode reads from br in UTF-8.
+type decodeReader struct {
+	br     *bufio.Reader
+	decode func(br *bufio.Reader) (rune, error)
+	buf    []byte
+	err    error
+
+	encoded [utf8.UTFMax]byte
+}
+
+func (d *decodeReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(d.buf) > 0 {
+			m := copy(p[n:], d.buf)
+			d.buf = d.buf[m:]
+			n += m
+			continue
+		}
+		// what's been read so far is returned rather than waiting for
+		// more input
+		if d.err != nil || (n > 0 && d.br.Buffered() == 0) {
+			break
+		}
+
+		r, err := d.decode(d.br)
+		if err != nil {
+			d.err = err
+			continue
+		}
+		d.buf = d.encoded[:utf8.EncodeRune(d.encoded[:], r)]
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+
+	return 0, d.err
+}
+
+// decodeUTF16 reads a character of UTF-16 with the byte order of unit.
+// Surrogates that aren't in a pair and a byte left at the end are
+// invalid.
+func decodeUTF16(br *bufio.Reader, unit func(b []byte) rune) (rune, error) {
+	b, err := br.Peek(2)
+	if len(b) < 2 {
+		if len(b) == 1 {
+			br.Discard(1)
+			return utf8.RuneError, nil
+		}
+		return 0, err
+	}
+	r := unit(b)
+	br.Discard(2)
+	if !utf16.IsSurrogate(r) {
+		return r, nil
+	}
+	if r >= 0xdc00 {
+		return utf8.RuneError, nil
+	}
+
+	b, _ = br.Peek(2)
+	if len(b) < 2 {
+		return utf8.RuneError, nil
+	}
+	if r = utf16.DecodeRune(r, unit(b)); r != utf8.RuneError {
+		br.Discard(2)
+	}
+
+	return r, nil
+}
+
+// windows1252 are the characters of Windows-1252 from 0x80 to 0x9f, where
+// it differs from Latin-1.
+var windows1252 = [32]rune{
+	'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+	utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+}
+
+func decodeWindows1252(br *bufio.Reader) (rune, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b >= 0x80 && b < 0xa0 {
+		return windows1252[b-0x80], nil
+	}
+
+	return rune(b), nil
+}
+
+// sjisTable holds the characters of sjisRows, indexed by sjisChar.
+var sjisTable = []rune(strings.Join(sjisRows[:], ""))
+
+func isSJISLead(b byte) bool {
+	return b >= 0x81 && b <= 0x9f || b >= 0xe0 && b <= 0xfc
+}
+
+// sjisChar returns the character of the two byte code of lead and trail,
+// or U+FFFD if there's none.
+func sjisChar(lead, trail byte) rune {
+	if !isSJISLead(lead) || trail < 0x40 || trail == 0x7f || trail > 0xfc {
+		return utf8.RuneError
+	}
+
+	row := int(lead) - 0x81
+	if lead >= 0xe0 {
+		row = int(lead) - 0xe0 + 0x1f
+	}
+	col := int(trail) - 0x40
+	if trail > 0x7f {
+		col--
+	}
+
+	return sjisTable[row*188+col]
+}
+
+func decodeShiftJIS(br *bufio.Reader) (rune, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case b < 0x80:
+		return rune(b), nil
+	case b >= 0xa1 && b <= 0xdf:
+		// half-width katakana
+		return 0xff61 + rune(b-0xa1), nil
+	case !isSJISLead(b):
+		return utf8.RuneError, nil
+	}
+
+	// a trail byte that's out of range is read again on its own, and one
+	// that's in range is part of the invalid sequence
+	t, err := br.Peek(1)
+	if err != nil {
+		return utf8.RuneError, nil
+	}
+	if t[0] >= 0x40 && t[0] <= 0xfc && t[0] != 0x7f {
+		br.Discard(1)
+	}
+
+	return sjisChar(b, t[0]), nil
+}
+
+// replacementChar is what invalid sequences are replaced with.
+const replacementChar = "�"
+
+// printReplaced prints text in kind to p, but for the replacement
+// characters in it, which are printed in the Warning kind.
+func printReplaced(w io.Writer, p syntaxhighlight.Printer, kind syntaxhighlight.Kind, text string) error {
+	for {
+		i := strings.Index(text, replacementChar)
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			if err := p.Print(w, kind, text[:i]); err != nil {
+				return err
+			}
+		}
+
+		n := len(replacementChar)
+		for strings.HasPrefix(text[i+n:], replacementChar) {
+			n += len(replacementChar)
+		}
+		if err := p.Print(w, Warning, text[i:i+n]); err != nil {
+			return err
+		}
+		text = text[i+n:]
+	}
+	if text == "" {
+		return nil
+	}
+
+	return p.Print(w, kind, text)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected string
+	}{
+		{"", "utf-8"},
+		{"plain ascii\n", "utf-8"},
+		{"caf\xc3\xa9\n", "utf-8"},
+		{"\xef\xbb\xbfwith a bom\n", "utf-8"},
+		// a character cut off by the end of the sniffed bytes
+		{"日本\xe8\xaa", "utf-8"},
+		{"\xff\xfeh\x00i\x00", "utf-16le"},
+		{"\xfe\xff\x00h\x00i", "utf-16be"},
+		// a stray byte of another encoding in UTF-8
+		{"日本語 café\nbad \xff byte", "utf-8"},
+		{"caf\xe9 cr\xe8me br\xfbl\xe9e\n", "windows-1252"},
+		{"\x93quoted\x94 \x80 5\n", "windows-1252"},
+		{"\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd\x81A\x90\xa2\x8aE\n", "shift_jis"},
+		{"\x82\xb1\x82\xf1\x82", "shift_jis"},
+		// half-width katakana alone could be accented letters
+		{"\xb6\xc0\xb6\xc5\n", "windows-1252"},
+		{"text\x00with a NUL \xff", ""},
+		{"\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", ""},
+	}
+
+	for _, tc := range cases {
+		if enc := detectEncoding([]byte(tc.Input)); enc != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %q\n\nExpected: %q", tc.Input, enc, tc.Expected)
+		}
+	}
+}
+
+func TestEncodingPrinter(t *testing.T) {
+	// more ASCII than the start of a file the encoding is told by
+	ascii := strings.Repeat("ascii\n", 1600)
+
+	cases := []struct {
+		Encoding string
+		Input    string
+		Expected string
+	}{
+		{EncodingAuto, "plain\n", "plain\n"},
+		{EncodingAuto, "\xef\xbb\xbfbom\n", "bom\n"},
+		{EncodingAuto, "\xff\xfeh\x00\xe9\x00=\xd8\x00\xde\n\x00", "hé😀\n"},
+		{EncodingAuto, "\xfe\xff\x00h\x00\xe9\xd8=\xde\x00\x00\n", "hé😀\n"},
+		{EncodingAuto, "caf\xe9 \x80\x85\n", "café €…\n"},
+		{EncodingAuto, "日本語 café\nbad \xff byte", "日本語 café\nbad � byte"},
+		{EncodingAuto, "\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd \xb6\xc0\n", "こんにちは ｶﾀ\n"},
+		{EncodingAuto, ascii + "caf\xe9\n", ascii + "café\n"},
+		{EncodingAuto, ascii + "日本語 café\n", ascii + "日本語 café\n"},
+		{EncodingAuto, ascii + "\x82\xb1\x82\xf1\n", ascii + "こん\n"},
+		// invalid sequences
+		{"utf-16le", "\x00\xdch\x00\x00\xd8", "�h�"},
+		{"utf-16be", "\x00h\x00", "h�"},
+		{"cp1252", "a\x81b", "a�b"},
+		{"sjis", "\x81 a\x85\xf0\xfd", "� a��"},
+		{"SJIS", "\x82\xa0", "あ"},
+		{"latin1", "\x80\xe9", "\u0080é"},
+		{"utf-8", "caf\xe9\n", "caf�\n"},
+		// a forced encoding takes the byte order marks of others for text
+		{"latin1", "\xef\xbb\xbf", "ï»¿"},
+	}
+
+	for _, tc := range cases {
+		readers := []io.Reader{strings.NewReader(tc.Input)}
+		// the encoding of input that trickles in is told by its first read
+		if tc.Encoding != EncodingAuto {
+			readers = append(readers, iotest.OneByteReader(strings.NewReader(tc.Input)))
+		}
+
+		for _, r := range readers {
+			var w bytes.Buffer
+			p := &EncodingPrinter{Printer: PlainTextPrinter{}, Encoding: tc.Encoding}
+			if err := p.Print(r, &w); err != nil {
+				t.Errorf("error should be nil, but it's %s", err)
+			}
+			if w.String() != tc.Expected {
+				t.Errorf("Encoding: %s\n\nInput: %q\n\nOutput: %q\n\nExpected: %q", tc.Encoding, tc.Input, w.String(), tc.Expected)
+			}
+		}
+	}
+}
+
+func TestEncodingReplacementWarning(t *testing.T) {
+	expected := []string{
+		`{"kind":"Plaintext","text":"x"`,
+		`{"kind":"Warning","text":"�"`,
+		`{"kind":"Warning","text":"�"`,
+		`{"kind":"Plaintext","text":"y"`,
+		`{"kind":"Whitespace","text":" "`,
+		`{"kind":"Comment","text":"// "`,
+		`{"kind":"Warning","text":"�"`,
+		`{"kind":"Whitespace","text":"\n"`,
+	}
+
+	for _, encoding := range []string{"windows-1252", "utf-8"} {
+		var w bytes.Buffer
+		p := &EncodingPrinter{Printer: JsonTokensPrinter{}, Encoding: encoding}
+		if err := p.Print(strings.NewReader("x\x81\x8dy // \x90\n"), &w); err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		var kinds []string
+		for _, line := range strings.Split(strings.TrimSpace(w.String()), "\n") {
+			kinds = append(kinds, line[:strings.Index(line, `,"offset"`)])
+		}
+		if strings.Join(kinds, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Encoding: %s\n\ntokens are wrong:\n%s", encoding, strings.Join(kinds, "\n"))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrIsDirectory is the error of a FILE argument that's a directory,
+	// which is only printed with -r.
+	ErrIsDirectory = errors.New("Is a directory")
+
+	// ErrNotInArchive is the error of an ARCHIVE:MEMBER argument whose
+	// archive has no such member.
+	ErrNotInArchive = errors.New("No such file in archive")
+
+	// ErrTooManyLinks is the error of an archive member that's a link to
+	// a link and so on, more than maxArchiveLinks times.
+	ErrTooManyLinks = errors.New("Too many levels of links")
+
+	// ErrNotArchive is the error of --list for a file that isn't a tar or
+	// zip archive.
+	ErrNotArchive = errors.New("Not a tar or zip archive")
+)
+
+// FileError is the error CCat and CCatTokens return when a file can't be
+// opened or read. Errors writing the output are returned as they are.
+type FileError struct {
+	Name string
+	Err  error
+}
+
+// Error formats e like cat does: missing: No such file or directory.
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, errorText(e.Err))
+}
+
+// errorText returns the message of err without the operation and path
+// *os.PathError adds to it, capitalized like the messages of strerror.
+func errorText(err error) string {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+
+	s := err.Error()
+	if _, ok := err.(syscall.Errno); ok {
+		r, size := utf8.DecodeRuneInString(s)
+		s = string(unicode.ToUpper(r)) + s[size:]
+	}
+
+	return s
+}
+
+// fileError wraps err in a FileError for fname unless it's one already.
+func fileError(fname string, err error) error {
+	if _, ok := err.(*FileError); ok || err == nil {
+		return err
+	}
+
+	return &FileError{Name: fname, Err: err}
+}
+
+// isBrokenPipe reports whether err comes from writing to a pipe whose
+// reader is gone, like head once it has read enough lines.
+func isBrokenPipe(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+
+	return err == syscall.EPIPE
+}
+
+// fileReader turns the errors reading a file into FileErrors, so that
+// they can be told apart from those writing the output.
+type fileReader struct {
+	r    io.Reader
+	name string
+}
+
+func (f fileReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = fileError(f.name, err)
+	}
+
+	return n, err
+}
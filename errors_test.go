@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCCatFileError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	missing := filepath.Join(dir, "missing")
+	cases := []struct {
+		Name     string
+		Expected string
+	}{
+		{missing, missing + ": No such file or directory"},
+		{dir, dir + ": Is a directory"},
+		{filepath.Join("testdata", "text.xz") + ":main.go", "testdata/text.xz: Not a tar or zip archive"},
+	}
+
+	for _, tc := range cases {
+		err := CCat(tc.Name, PlainTextPrinter{}, ioutil.Discard)
+		if _, ok := err.(*FileError); !ok {
+			t.Errorf("Name: %s\n\nerror should be a *FileError, but it's %#v", tc.Name, err)
+			continue
+		}
+		if err.Error() != filepath.FromSlash(tc.Expected) {
+			t.Errorf("Name: %s\n\nOutput: %s\n\nExpected: %s", tc.Name, err, tc.Expected)
+		}
+	}
+}
+
+// errReader fails after its content.
+type errReader struct {
+	s string
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if r.s == "" {
+		return 0, syscall.EIO
+	}
+	n := copy(p, r.s)
+	r.s = r.s[n:]
+
+	return n, nil
+}
+
+func TestFileReader(t *testing.T) {
+	_, err := ioutil.ReadAll(fileReader{&errReader{"text"}, "disk.txt"})
+	if err == nil || err.Error() != "disk.txt: Input/output error" {
+		t.Errorf("error is wrong: %v", err)
+	}
+
+	output, err := ioutil.ReadAll(fileReader{strings.NewReader("text"), "text.txt"})
+	if err != nil || string(output) != "text" {
+		t.Errorf("output is wrong: %q %v", output, err)
+	}
+}
+
+func TestIsBrokenPipe(t *testing.T) {
+	cases := []struct {
+		Err      error
+		Expected bool
+	}{
+		{&os.PathError{Op: "write", Path: "/dev/stdout", Err: syscall.EPIPE}, true},
+		{os.NewSyscallError("write", syscall.EPIPE), true},
+		{syscall.EPIPE, true},
+		{errors.New("broken pipe"), false},
+		{nil, false},
+	}
+
+	for _, tc := range cases {
+		if isBrokenPipe(tc.Err) != tc.Expected {
+			t.Errorf("Err: %v\n\nOutput: %v", tc.Err, !tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// followInterval is how often a followed file is checked for new content
+// when Follower.Interval isn't set.
+const followInterval = 200 * time.Millisecond
+
+// Follower prints files like tail. Tail is the number of lines at the end
+// of a file printing starts from, or -1 to print it all. With Follow, the
+// file is kept open and what's appended to it is printed as it arrives,
+// until Stop is closed. Like tail -F, a followed file that's truncated is
+// printed again from its start, and one that's replaced, as when logs are
+// rotated, is followed by name.
+type Follower struct {
+	Tail     int
+	Follow   bool
+	Interval time.Duration
+	Stop     <-chan struct{}
+}
+
+// CCat prints fname with p, like CCat.
+func (f *Follower) CCat(fname string, p CCatPrinter, w io.Writer) error {
+	// standard input is followed anyway, and archive members can't grow
+	_, _, member := splitArchiveMember(fname)
+	if !f.Follow || fname == readFromStdin || member {
+		return f.print(fname, p, w)
+	}
+
+	r, err := f.open(fname)
+	if err != nil {
+		return fileError(fname, err)
+	}
+	defer r.Close()
+
+	// the head is read from the start of the file, whatever line printing
+	// starts from
+	head := make([]byte, sniffLen)
+	n, _ := r.file.ReadAt(head, 0)
+	head = head[:n]
+
+	// what's appended to compressed and binary files can't be printed on
+	// its own, so they're printed as they are
+	if compressionFormat(head) >= 0 || isBinaryHead(head) {
+		r.Close()
+		return f.print(fname, p, w)
+	}
+
+	if fp, ok := p.(FilePrinter); ok {
+		fp.SetFile(File{Name: fname, Size: r.info.Size(), Head: head})
+	}
+
+	return p.Print(fileReader{r, fname}, w)
+}
+
+// print prints fname without following it.
+func (f *Follower) print(fname string, p CCatPrinter, w io.Writer) error {
+	return ccat(fname, func(file File, r io.Reader) error {
+		if fp, ok := p.(FilePrinter); ok {
+			fp.SetFile(file)
+		}
+		if f.Tail >= 0 {
+			var err error
+			if r, err = lastLines(r, f.Tail); err != nil {
+				return err
+			}
+		}
+		return p.Print(r, w)
+	})
+}
+
+func (f *Follower) open(fname string) (*followReader, error) {
+	file, _, err := openFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	r := &followReader{file: file.(*os.File), name: fname, interval: f.Interval, stop: f.Stop}
+	if r.interval <= 0 {
+		r.interval = followInterval
+	}
+
+	if r.info, err = r.file.Stat(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if f.Tail >= 0 {
+		if r.offset, err = tailOffset(r.file, r.info.Size(), f.Tail); err != nil {
+			r.Close()
+			return nil, err
+		}
+		if _, err := r.file.Seek(r.offset, io.SeekStart); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// followReader reads a file without ever reaching its end, waiting for
+// more to be written to it instead.
+type followReader struct {
+	file     *os.File
+	info     os.FileInfo
+	name     string
+	offset   int64
+	interval time.Duration
+	stop     <-chan struct{}
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		f.offset += int64(n)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+
+		reopened, err := f.reopen()
+		if err != nil {
+			return 0, err
+		}
+		if reopened {
+			continue
+		}
+
+		select {
+		case <-f.stop:
+			return 0, io.EOF
+		case <-time.After(f.interval):
+		}
+	}
+}
+
+// reopen starts over from the beginning of the file if it has been
+// truncated or replaced by another one since it was opened. A file that's
+// gone is waited for.
+func (f *followReader) reopen() (bool, error) {
+	info, err := os.Stat(f.name)
+	if err != nil {
+		return false, nil
+	}
+
+	if !os.SameFile(info, f.info) {
+		file, err := os.Open(f.name)
+		if err != nil {
+			return false, nil
+		}
+		f.file.Close()
+		f.file, f.info, f.offset = file, info, 0
+		return true, nil
+	}
+
+	if info.Size() < f.offset {
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		f.offset = 0
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (f *followReader) Close() error {
+	return f.file.Close()
+}
+
+// tailOffset returns the offset of the last n lines of the size bytes of
+// r. The line break at the end of the last line doesn't start another.
+func tailOffset(r io.ReaderAt, size int64, n int) (int64, error) {
+	if n == 0 {
+		return size, nil
+	}
+
+	buf := make([]byte, 4096)
+	for pos := size; pos > 0; {
+		m := int64(len(buf))
+		if m > pos {
+			m = pos
+		}
+		pos -= m
+		if _, err := r.ReadAt(buf[:m], pos); err != nil {
+			return 0, err
+		}
+
+		for i := m - 1; i >= 0; i-- {
+			if buf[i] != '\n' || pos+i == size-1 {
+				continue
+			}
+			if n--; n == 0 {
+				return pos + i + 1, nil
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+// lastLines returns a reader of the last n lines of r, which is read to
+// its end keeping no more than n lines in memory.
+func lastLines(r io.Reader, n int) (io.Reader, error) {
+	if n == 0 {
+		return strings.NewReader(""), nil
+	}
+
+	lines := make([]string, n)
+	br := bufio.NewReader(r)
+	i := 0
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			lines[i%n] = line
+			i++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if i < n {
+		return strings.NewReader(strings.Join(lines[:i], "")), nil
+	}
+	lines = append(lines[i%n:], lines[:i%n]...)
+
+	return strings.NewReader(strings.Join(lines, "")), nil
+}
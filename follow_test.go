@@ -0,0 +1,216 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTailOffset(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Lines    int
+		Expected int64
+	}{
+		{"", 3, 0},
+		{"a\nb\nc\n", 0, 6},
+		{"a\nb\nc\n", 1, 4},
+		{"a\nb\nc\n", 2, 2},
+		{"a\nb\nc\n", 3, 0},
+		{"a\nb\nc\n", 10, 0},
+		{"a\nb\nc", 1, 4},
+		{"a\n\n\n", 2, 2},
+		{strings.Repeat("line\n", 2000), 1000, 5000},
+	}
+
+	for _, tc := range cases {
+		offset, err := tailOffset(strings.NewReader(tc.Input), int64(len(tc.Input)), tc.Lines)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+		if offset != tc.Expected {
+			t.Errorf("Input: %.20q %d\n\nOutput: %d\n\nExpected: %d", tc.Input, tc.Lines, offset, tc.Expected)
+		}
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Lines    int
+		Expected string
+	}{
+		{"", 3, ""},
+		{"a\nb\nc\n", 0, ""},
+		{"a\nb\nc\n", 2, "b\nc\n"},
+		{"a\nb\nc", 1, "c"},
+		{"a\nb\nc\n", 5, "a\nb\nc\n"},
+		{"a\nb\nc\nd\ne\n", 3, "c\nd\ne\n"},
+	}
+
+	for _, tc := range cases {
+		r, err := lastLines(strings.NewReader(tc.Input), tc.Lines)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+			continue
+		}
+		output, _ := ioutil.ReadAll(r)
+		if string(output) != tc.Expected {
+			t.Errorf("Input: %q %d\n\nOutput: %q\n\nExpected: %q", tc.Input, tc.Lines, output, tc.Expected)
+		}
+	}
+}
+
+// waitFor reads out until what's been printed contains s.
+func waitFor(t *testing.T, out lineWriter, s string) {
+	var printed string
+	timeout := time.After(5 * time.Second)
+	for !strings.Contains(printed, s) {
+		select {
+		case p := <-out:
+			printed += p
+		case <-timeout:
+			t.Fatalf("%q isn't printed: %q", s, printed)
+		}
+	}
+}
+
+func TestFollower(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(fname, []byte("old\nlast\n/* comment"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	out := make(lineWriter, 100)
+	done := make(chan error)
+	go func() {
+		f := &Follower{Tail: 2, Follow: true, Interval: 10 * time.Millisecond, Stop: stop}
+		done <- f.CCat(fname, JsonTokensPrinter{}, out)
+	}()
+
+	waitFor(t, out, `"text":"last"`)
+
+	appendFile := func(s string) {
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	// a comment appended in two chunks is a single token
+	appendFile(" over\n")
+	time.Sleep(50 * time.Millisecond)
+	appendFile("lines */ x := `raw\n")
+	time.Sleep(50 * time.Millisecond)
+	appendFile("string`\n")
+	waitFor(t, out, `{"kind":"Comment","text":"/* comment over\nlines */"`)
+	waitFor(t, out, "{\"kind\":\"String\",\"text\":\"`raw\\nstring`\"")
+
+	// truncated
+	if err := ioutil.WriteFile(fname, []byte("truncated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, out, `"text":"truncated"`)
+
+	// rotated
+	if err := os.Rename(fname, fname+".1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := ioutil.WriteFile(fname, []byte("rotated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, out, `"text":"rotated"`)
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+}
+
+func TestFollowerUnclosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "access.log")
+	if err := ioutil.WriteFile(fname, []byte("start\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	out := make(lineWriter, 100)
+	done := make(chan error)
+	go func() {
+		f := &Follower{Tail: -1, Follow: true, Interval: 10 * time.Millisecond, Stop: stop}
+		done <- f.CCat(fname, JsonTokensPrinter{}, out)
+	}()
+
+	waitFor(t, out, `"text":"start"`)
+
+	// a line with an unclosed comment is printed once nothing more is
+	// appended, and doesn't hold back the lines after it
+	for _, tc := range []struct{ Append, Expected string }{
+		{"GET /static/* 200\n", `{"kind":"Comment","text":"/* 200"`},
+		{"more\n", `{"kind":"Plaintext","text":"more"`},
+	} {
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(tc.Append)
+		f.Close()
+
+		waitFor(t, out, tc.Expected)
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+}
+
+func TestFollowerTail(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Lines    int
+		Follow   bool
+		Expected string
+	}{
+		{filepath.Join("testdata", "text.xz"), 2, false, "2998: the quick brown fox jumps over the lazy alpha\n2999: the quick brown fox jumps over the lazy delta\n"},
+		{filepath.Join("testdata", "text.xz"), 0, false, ""},
+		// compressed files aren't followed
+		{filepath.Join("testdata", "text.xz"), 2, true, "2998: the quick brown fox jumps over the lazy alpha\n2999: the quick brown fox jumps over the lazy delta\n"},
+	}
+
+	for _, tc := range cases {
+		out := make(lineWriter, 100)
+		f := &Follower{Tail: tc.Lines, Follow: tc.Follow}
+		if err := f.CCat(tc.Name, PlainTextPrinter{}, out); err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+		close(out)
+
+		var output string
+		for s := range out {
+			output += s
+		}
+		if output != tc.Expected {
+			t.Errorf("Name: %s\n\nOutput: %q\n\nExpected: %q", tc.Name, output, tc.Expected)
+		}
+	}
+}
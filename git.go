@@ -0,0 +1,598 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Types of git objects as stored in pack files
+const (
+	gitObjCommit   = 1
+	gitObjTree     = 2
+	gitObjBlob     = 3
+	gitObjTag      = 4
+	gitObjOfsDelta = 6
+	gitObjRefDelta = 7
+)
+
+// gitMaxDeltaDepth is the longest chain of deltas an object is read
+// through, which is the most git pack-objects --depth allows.
+const gitMaxDeltaDepth = 4095
+
+type gitHash [sha1.Size]byte
+
+func (h gitHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// gitRepo reads the index and the objects of a repository from its
+// .git directory without calling git.
+type gitRepo struct {
+	WorkTree string
+	GitDir   string
+	// CommonDir holds the objects, it differs from GitDir for
+	// linked worktrees
+	CommonDir string
+
+	mu      sync.Mutex
+	entries map[string]gitHash
+	packs   []*gitPack
+}
+
+// gitRepos are the repositories found so far by their git directory, so
+// that the index and the pack indexes are read once for all the files in
+// one.
+var gitRepos = struct {
+	sync.Mutex
+	m map[string]*gitRepo
+}{m: make(map[string]*gitRepo)}
+
+// cachedGitRepo returns the repository found before with the git
+// directory of g, or g if there's none.
+func cachedGitRepo(g *gitRepo) *gitRepo {
+	gitRepos.Lock()
+	defer gitRepos.Unlock()
+
+	if r, ok := gitRepos.m[g.GitDir]; ok {
+		return r
+	}
+	gitRepos.m[g.GitDir] = g
+
+	return g
+}
+
+// findGitRepo looks for the repository that contains path.
+func findGitRepo(path string) (*gitRepo, error) {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		fi, err := os.Stat(dotGit)
+		if err == nil {
+			gitDir := dotGit
+			if !fi.IsDir() {
+				gitDir, err = readGitDirFile(dotGit)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			commonDir := gitDir
+			if b, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+				commonDir = resolvePath(gitDir, strings.TrimSpace(string(b)))
+			}
+
+			return cachedGitRepo(&gitRepo{WorkTree: dir, GitDir: gitDir, CommonDir: commonDir}), nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("%s is not in a git repository", path)
+		}
+		dir = parent
+	}
+}
+
+// readGitDirFile reads a .git file of a submodule or a linked worktree,
+// which points to the actual git directory.
+func readGitDirFile(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	s := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(s, "gitdir: ") {
+		return "", fmt.Errorf("invalid gitdir file: %s", name)
+	}
+
+	return resolvePath(filepath.Dir(name), strings.TrimPrefix(s, "gitdir: ")), nil
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// IndexBlob returns the hash of the blob staged for path.
+func (g *gitRepo) IndexBlob(path string) (gitHash, error) {
+	var h gitHash
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return h, err
+	}
+	rel, err := filepath.Rel(g.WorkTree, abs)
+	if err != nil {
+		return h, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	entries, err := g.index()
+	if err != nil {
+		return h, err
+	}
+
+	h, ok := entries[rel]
+	if !ok {
+		return h, fmt.Errorf("%s is not in the git index", path)
+	}
+
+	return h, nil
+}
+
+// index returns the entries of the index, which is read the first time.
+func (g *gitRepo) index() (map[string]gitHash, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.entries != nil {
+		return g.entries, nil
+	}
+
+	f, err := os.Open(filepath.Join(g.GitDir, "index"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := readGitIndex(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	g.entries = entries
+
+	return entries, nil
+}
+
+// readGitIndex reads the paths and blob hashes of the stage 0 entries
+// of an index file in version 2, 3 or 4.
+func readGitIndex(r *bufio.Reader) (map[string]gitHash, error) {
+	var header struct {
+		Signature [4]byte
+		Version   uint32
+		Entries   uint32
+	}
+	err := binary.Read(r, binary.BigEndian, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(header.Signature[:]) != "DIRC" {
+		return nil, fmt.Errorf("invalid git index signature")
+	}
+	if header.Version < 2 || header.Version > 4 {
+		return nil, fmt.Errorf("unsupported git index version %d", header.Version)
+	}
+
+	entries := make(map[string]gitHash, header.Entries)
+	var name []byte
+	for i := uint32(0); i < header.Entries; i++ {
+		// ctime, mtime, dev, ino, mode, uid, gid and size
+		var fixed [40]byte
+		var h gitHash
+		var flags uint16
+		if _, err := io.ReadFull(r, fixed[:]); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.BigEndian, &flags); err != nil {
+			return nil, err
+		}
+		entryLen := len(fixed) + len(h) + 2
+
+		if flags&0x4000 != 0 && header.Version >= 3 {
+			var extended uint16
+			if err := binary.Read(r, binary.BigEndian, &extended); err != nil {
+				return nil, err
+			}
+			entryLen += 2
+		}
+
+		if header.Version == 4 {
+			// the name is prefix compressed against the previous one
+			strip, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			if strip > uint64(len(name)) {
+				return nil, fmt.Errorf("invalid git index entry")
+			}
+			suffix, err := r.ReadBytes(0)
+			if err != nil {
+				return nil, err
+			}
+			name = append(name[:len(name)-int(strip)], suffix[:len(suffix)-1]...)
+		} else {
+			b, err := r.ReadBytes(0)
+			if err != nil {
+				return nil, err
+			}
+			name = b[:len(b)-1]
+			entryLen += len(b)
+
+			// entries are padded with NULs to a multiple of 8 bytes
+			if pad := (8 - entryLen%8) % 8; pad > 0 {
+				if _, err := r.Discard(pad); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if stage := (flags >> 12) & 0x3; stage == 0 {
+			entries[string(name)] = h
+		}
+	}
+
+	return entries, nil
+}
+
+// ReadBlob returns the content of a blob, looking for it in the loose
+// objects first and in the pack files after.
+func (g *gitRepo) ReadBlob(h gitHash) ([]byte, error) {
+	typ, data, err := g.readObject(h, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != gitObjBlob {
+		return nil, fmt.Errorf("git object %s is not a blob", h)
+	}
+
+	return data, nil
+}
+
+// readObject reads the object h, which is the base of depth deltas.
+func (g *gitRepo) readObject(h gitHash, depth int) (int, []byte, error) {
+	s := h.String()
+	f, err := os.Open(filepath.Join(g.CommonDir, "objects", s[:2], s[2:]))
+	if err == nil {
+		defer f.Close()
+		return readLooseObject(f)
+	}
+
+	packs, err := g.loadPacks()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, p := range packs {
+		if offset, ok := p.Find(h); ok {
+			return p.ReadObject(g, offset, depth)
+		}
+	}
+
+	return 0, nil, fmt.Errorf("git object %s not found", h)
+}
+
+func readLooseObject(r io.Reader) (int, []byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return 0, nil, fmt.Errorf("invalid git object header")
+	}
+
+	header := strings.SplitN(string(b[:i]), " ", 2)
+	types := map[string]int{
+		"commit": gitObjCommit,
+		"tree":   gitObjTree,
+		"blob":   gitObjBlob,
+		"tag":    gitObjTag,
+	}
+	typ, ok := types[header[0]]
+	if !ok || len(header) != 2 {
+		return 0, nil, fmt.Errorf("invalid git object header")
+	}
+
+	size, err := strconv.Atoi(header[1])
+	if err != nil || size != len(b)-i-1 {
+		return 0, nil, fmt.Errorf("invalid git object size")
+	}
+
+	return typ, b[i+1:], nil
+}
+
+// loadPacks returns the pack files, whose indexes are read the first time.
+func (g *gitRepo) loadPacks() ([]*gitPack, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.packs != nil {
+		return g.packs, nil
+	}
+
+	idxs, err := filepath.Glob(filepath.Join(g.CommonDir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+
+	packs := []*gitPack{}
+	for _, idx := range idxs {
+		p, err := readGitPackIndex(idx)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, p)
+	}
+	g.packs = packs
+
+	return packs, nil
+}
+
+// gitPack is a pack file with its version 2 index.
+type gitPack struct {
+	Path    string
+	Hashes  []gitHash
+	Offsets []int64
+
+	open sync.Once
+	f    *os.File
+	err  error
+}
+
+func readGitPackIndex(name string) (*gitPack, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < 8+256*4 || !bytes.Equal(b[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(b[4:]) != 2 {
+		return nil, fmt.Errorf("unsupported git pack index: %s", name)
+	}
+
+	n := int(binary.BigEndian.Uint32(b[8+255*4:]))
+	hashes := b[8+256*4:]
+	if len(hashes) < n*(sha1.Size+8) {
+		return nil, fmt.Errorf("invalid git pack index: %s", name)
+	}
+	offsets := hashes[n*(sha1.Size+4):]
+	large := offsets[n*4:]
+
+	p := &gitPack{
+		Path:    strings.TrimSuffix(name, ".idx") + ".pack",
+		Hashes:  make([]gitHash, n),
+		Offsets: make([]int64, n),
+	}
+	for i := 0; i < n; i++ {
+		copy(p.Hashes[i][:], hashes[i*sha1.Size:])
+
+		offset := binary.BigEndian.Uint32(offsets[i*4:])
+		if offset&0x80000000 == 0 {
+			p.Offsets[i] = int64(offset)
+			continue
+		}
+
+		j := int(offset&0x7fffffff) * 8
+		if j+8 > len(large) {
+			return nil, fmt.Errorf("invalid git pack index: %s", name)
+		}
+		p.Offsets[i] = int64(binary.BigEndian.Uint64(large[j:]))
+	}
+
+	return p, nil
+}
+
+func (p *gitPack) Find(h gitHash) (int64, bool) {
+	i := sort.Search(len(p.Hashes), func(i int) bool {
+		return bytes.Compare(p.Hashes[i][:], h[:]) >= 0
+	})
+	if i < len(p.Hashes) && p.Hashes[i] == h {
+		return p.Offsets[i], true
+	}
+
+	return 0, false
+}
+
+// ReadObject reads the object at offset, resolving deltas against their
+// base objects. depth is the number of deltas the object is the base of.
+func (p *gitPack) ReadObject(g *gitRepo, offset int64, depth int) (int, []byte, error) {
+	if depth > gitMaxDeltaDepth {
+		return 0, nil, fmt.Errorf("git delta chain is too long in %s", p.Path)
+	}
+
+	// the pack is kept open for the objects read from it later
+	p.open.Do(func() {
+		p.f, p.err = os.Open(p.Path)
+	})
+	if p.err != nil {
+		return 0, nil, p.err
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(p.f, offset, 1<<62))
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := int(c>>4) & 7
+	size := uint64(c & 0x0f)
+	for shift := uint(4); c&0x80 != 0; shift += 7 {
+		c, err = r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+
+	var baseType int
+	var base []byte
+	switch typ {
+	case gitObjOfsDelta:
+		c, err = r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			c, err = r.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			// the base is before the delta, which checking each step
+			// keeps from overflowing too
+			if rel >= offset {
+				break
+			}
+			rel = (rel+1)<<7 | int64(c&0x7f)
+		}
+		if rel <= 0 || rel >= offset {
+			return 0, nil, fmt.Errorf("invalid git delta base offset in %s", p.Path)
+		}
+		baseType, base, err = p.ReadObject(g, offset-rel, depth+1)
+	case gitObjRefDelta:
+		var h gitHash
+		if _, err = io.ReadFull(r, h[:]); err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err = g.readObject(h, depth+1)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+
+	// the size in the header is only trusted as far as the data goes
+	if size > 1<<62 {
+		return 0, nil, fmt.Errorf("invalid git object size in %s", p.Path)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(zr, int64(size)))
+	if err != nil {
+		return 0, nil, err
+	}
+	if uint64(len(data)) != size {
+		return 0, nil, fmt.Errorf("invalid git object size in %s", p.Path)
+	}
+
+	if base == nil {
+		return typ, data, nil
+	}
+
+	data, err = applyGitDelta(base, data)
+
+	return baseType, data, err
+}
+
+// applyGitDelta builds an object from base and the copy and insert
+// instructions of delta.
+func applyGitDelta(base, delta []byte) ([]byte, error) {
+	errInvalid := fmt.Errorf("invalid git delta")
+
+	r := bytes.NewReader(delta)
+	baseSize, err := binary.ReadUvarint(r)
+	if err != nil || baseSize != uint64(len(base)) {
+		return nil, errInvalid
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, errInvalid
+	}
+
+	// the size is only trusted as far as the delta could make it
+	capacity := uint64(len(base) + len(delta))
+	if size < capacity {
+		capacity = size
+	}
+	out := make([]byte, 0, capacity)
+	for {
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+
+		switch {
+		case op&0x80 != 0:
+			var offset, n uint32
+			for i := uint(0); i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				b, err := r.ReadByte()
+				if err != nil {
+					return nil, errInvalid
+				}
+				if i < 4 {
+					offset |= uint32(b) << (8 * i)
+				} else {
+					n |= uint32(b) << (8 * (i - 4))
+				}
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if uint64(offset)+uint64(n) > uint64(len(base)) {
+				return nil, errInvalid
+			}
+			out = append(out, base[offset:offset+n]...)
+		case op > 0:
+			start := len(out)
+			out = append(out, make([]byte, op)...)
+			if _, err := io.ReadFull(r, out[start:]); err != nil {
+				return nil, errInvalid
+			}
+		default:
+			return nil, errInvalid
+		}
+	}
+
+	if uint64(len(out)) != size {
+		return nil, errInvalid
+	}
+
+	return out, nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeGitRepo creates a repository in dir whose index has files with
+// the given contents, stored as loose objects.
+func writeGitRepo(t *testing.T, dir string, files map[string]string) {
+	var index bytes.Buffer
+	index.WriteString("DIRC")
+	binary.Write(&index, binary.BigEndian, uint32(2))
+	binary.Write(&index, binary.BigEndian, uint32(len(files)))
+
+	for name, content := range files {
+		obj := []byte(fmt.Sprintf("blob %d\x00%s", len(content), content))
+		h := gitHash(sha1.Sum(obj))
+
+		path := filepath.Join(dir, ".git", "objects", h.String()[:2], h.String()[2:])
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(obj)
+		zw.Close()
+		if err := ioutil.WriteFile(path, z.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		start := index.Len()
+		index.Write(make([]byte, 40))
+		index.Write(h[:])
+		binary.Write(&index, binary.BigEndian, uint16(len(name)))
+		index.WriteString(name)
+		index.WriteByte(0)
+		for (index.Len()-start)%8 != 0 {
+			index.WriteByte(0)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, ".git", "index"), index.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeGitRepo(t, dir, map[string]string{
+		"src/main.go": "a\nb\nc\n",
+	})
+
+	path := filepath.Join(dir, "src", "main.go")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := ioutil.WriteFile(path, []byte("a\nx\nc\nd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := gitChanges(path)
+	if err != nil {
+		t.Fatalf("error should be nil, but it's %s", err)
+	}
+
+	expect := map[int]lineChange{2: lineModified, 4: lineAdded}
+	if !reflect.DeepEqual(changes, expect) {
+		t.Errorf("changes are wrong: %v", changes)
+	}
+
+	// the repository is read once for all its files
+	r1, err1 := findGitRepo(path)
+	r2, err2 := findGitRepo(filepath.Join(dir, "README"))
+	if err1 != nil || err2 != nil || r1 != r2 {
+		t.Errorf("repository isn't cached: %p %p", r1, r2)
+	}
+}
+
+func TestApplyGitDelta(t *testing.T) {
+	base := []byte("hello world")
+	delta := []byte{
+		11, 13, // base and result sizes
+		0x90, 6, // copy 6 bytes from offset 0
+		7, 'g', 'o', 'p', 'h', 'e', 'r', 's', // insert 7 bytes
+	}
+
+	out, err := applyGitDelta(base, delta)
+	if err != nil {
+		t.Fatalf("error should be nil, but it's %s", err)
+	}
+
+	if string(out) != "hello gophers" {
+		t.Errorf("output is wrong: %q", out)
+	}
+}
+
+func TestGutterPrinter(t *testing.T) {
+	var w bytes.Buffer
+	p := &GutterPrinter{Printer: PlainTextPrinter{}, Numbers: true, Changes: true}
+	p.SetFile(File{Name: readFromStdin})
+
+	err := p.Print(bytes.NewBufferString("a\n\nb"), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expect := "   1   │ a\n   2   │ \n   3   │ b"
+	if w.String() != expect {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+// writeGitPack writes a pack file and its index with objects at the given
+// hashes, which are sorted, to the objects directory of dir.
+func writeGitPack(t *testing.T, dir string, hashes []gitHash, objects [][]byte) {
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+	binary.Write(&pack, binary.BigEndian, uint32(len(objects)))
+
+	offsets := make(map[gitHash]uint32)
+	for i, obj := range objects {
+		offsets[hashes[i]] = uint32(pack.Len())
+		pack.Write(obj)
+	}
+
+	var idx bytes.Buffer
+	idx.Write([]byte{0xff, 't', 'O', 'c'})
+	binary.Write(&idx, binary.BigEndian, uint32(2))
+	for b := 0; b < 256; b++ {
+		n := 0
+		for _, h := range hashes {
+			if int(h[0]) <= b {
+				n++
+			}
+		}
+		binary.Write(&idx, binary.BigEndian, uint32(n))
+	}
+	for _, h := range hashes {
+		idx.Write(h[:])
+	}
+	idx.Write(make([]byte, 4*len(hashes)))
+	for _, h := range hashes {
+		binary.Write(&idx, binary.BigEndian, offsets[h])
+	}
+
+	name := filepath.Join(dir, "objects", "pack", "pack-test")
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name+".pack", pack.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name+".idx", idx.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// packObject returns a pack entry of typ with the given data, after the
+// base reference of a delta.
+func packObject(typ int, base []byte, data []byte) []byte {
+	var b bytes.Buffer
+	size := len(data)
+	c := byte(typ<<4) | byte(size&0x0f)
+	for size >>= 4; size > 0; size >>= 7 {
+		b.WriteByte(c | 0x80)
+		c = byte(size & 0x7f)
+	}
+	b.WriteByte(c)
+	b.Write(base)
+
+	zw := zlib.NewWriter(&b)
+	zw.Write(data)
+	zw.Close()
+
+	return b.Bytes()
+}
+
+func TestGitPackDeltas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hash := func(b byte) gitHash {
+		var h gitHash
+		h[0] = b
+		return h
+	}
+	blob, delta, cycleA, cycleB, self := hash(1), hash(2), hash(3), hash(4), hash(5)
+
+	blobObj := packObject(gitObjBlob, nil, []byte("hello world"))
+	gophers := []byte{11, 13, 0x90, 6, 7, 'g', 'o', 'p', 'h', 'e', 'r', 's'}
+	writeGitPack(t, dir, []gitHash{blob, delta, cycleA, cycleB, self}, [][]byte{
+		blobObj,
+		packObject(gitObjOfsDelta, []byte{byte(len(blobObj))}, gophers),
+		packObject(gitObjRefDelta, cycleB[:], gophers),
+		packObject(gitObjRefDelta, cycleA[:], gophers),
+		packObject(gitObjOfsDelta, []byte{0}, gophers),
+	})
+
+	repo := &gitRepo{CommonDir: dir}
+	data, err := repo.ReadBlob(delta)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+	if string(data) != "hello gophers" {
+		t.Errorf("output is wrong: %q", data)
+	}
+
+	// deltas that are their own base are invalid rather than read forever
+	for _, h := range []gitHash{cycleA, self} {
+		if _, err := repo.ReadBlob(h); err == nil {
+			t.Errorf("reading %s should fail", h)
+		}
+	}
+}
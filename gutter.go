@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+const gutterNumberWidth = 4
+
+var gutterMarkers = map[lineChange]struct {
+	Kind syntaxhighlight.Kind
+	Text string
+}{
+	lineAdded:        {GitAdded, "+"},
+	lineModified:     {GitModified, "~"},
+	lineRemovedAbove: {GitRemoved, "‾"},
+	lineRemovedBelow: {GitRemoved, "_"},
+}
+
+// GutterPrinter prefixes every line with its number and a marker for
+// how it changed compared to the git index. The gutter of the Target line
+// is emphasized with the TargetLine kind.
+type GutterPrinter struct {
+	Printer CCatPrinter
+	Numbers bool
+	Changes bool
+	Target  int
+
+	changes map[int]lineChange
+	line    int
+	midLine bool
+}
+
+func (g *GutterPrinter) SetFile(file File) {
+	g.line = 0
+	g.midLine = false
+	g.changes = nil
+
+	if g.Changes && file.Name != readFromStdin {
+		// files outside of a repository simply have no markers
+		g.changes, _ = gitChanges(file.Name)
+	}
+
+	if f, ok := g.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (g *GutterPrinter) Print(r io.Reader, w io.Writer) error {
+	return g.PrintTokens(Lex(r), w)
+}
+
+func (g *GutterPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(g.Printer, func(w io.Writer, p syntaxhighlight.Printer) error {
+		return src(w, gutterCodePrinter{g, p})
+	}, w)
+}
+
+type gutterCodePrinter struct {
+	*GutterPrinter
+	p syntaxhighlight.Printer
+}
+
+func (g gutterCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	for len(tokText) > 0 {
+		if !g.midLine {
+			g.midLine = true
+			g.line++
+			if err := g.printGutter(w); err != nil {
+				return err
+			}
+		}
+
+		i := strings.IndexByte(tokText, '\n')
+		if i < 0 {
+			return g.p.Print(w, kind, tokText)
+		}
+
+		if i > 0 {
+			if err := g.p.Print(w, kind, tokText[:i]); err != nil {
+				return err
+			}
+		}
+		if err := g.p.Print(w, syntaxhighlight.Whitespace, "\n"); err != nil {
+			return err
+		}
+
+		g.midLine = false
+		tokText = tokText[i+1:]
+	}
+
+	return nil
+}
+
+func (g gutterCodePrinter) printGutter(w io.Writer) error {
+	kind := LineNumber
+	if g.line == g.Target {
+		kind = TargetLine
+	}
+
+	if g.Numbers {
+		if err := g.p.Print(w, kind, fmt.Sprintf("%*d", gutterNumberWidth, g.line)); err != nil {
+			return err
+		}
+		if err := g.p.Print(w, syntaxhighlight.Whitespace, " "); err != nil {
+			return err
+		}
+	}
+
+	if g.Changes {
+		m, ok := gutterMarkers[g.changes[g.line]]
+		if !ok {
+			m.Kind, m.Text = syntaxhighlight.Whitespace, " "
+		}
+		if err := g.p.Print(w, m.Kind, m.Text); err != nil {
+			return err
+		}
+		if err := g.p.Print(w, syntaxhighlight.Whitespace, " "); err != nil {
+			return err
+		}
+	}
+
+	if err := g.p.Print(w, kind, "│"); err != nil {
+		return err
+	}
+
+	return g.p.Print(w, syntaxhighlight.Whitespace, " ")
+}
+
+// gitChanges compares the file at path to its version in the git index.
+func gitChanges(path string) (map[int]lineChange, error) {
+	repo, err := findGitRepo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := repo.IndexBlob(path)
+	if err != nil {
+		return nil, err
+	}
+
+	staged, err := repo.ReadBlob(h)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffLines(splitLines(string(staged)), splitLines(string(current))), nil
+}
+
+func splitLines(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// slotLimit is how much output a file that isn't due yet holds before its
+// printing waits for its turn.
+const slotLimit = 1 << 20
+
+// printOrdered prints n files with print in up to jobs goroutines at once.
+// The output of each file reaches out in the order of the files: the first
+// file that isn't done is written as it's printed, and the ones after it
+// are buffered until their turn, up to slotLimit each. done is called with
+// the error of each file in order, and stops the printing by returning
+// false.
+func printOrdered(n, jobs int, out io.Writer, print func(i int, w io.Writer) error, done func(i int, err error) bool) {
+	slots := make([]*outputSlot, n)
+	for i := range slots {
+		slots[i] = newOutputSlot()
+	}
+
+	// a file is started once a slot is free, which is when the file jobs
+	// places before it has been written
+	free := make(chan struct{}, jobs)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i, s := range slots {
+			select {
+			case free <- struct{}{}:
+			case <-stop:
+				return
+			}
+			go func(i int, s *outputSlot) {
+				s.done <- print(i, s)
+			}(i, s)
+		}
+	}()
+
+	for i, s := range slots {
+		werr := s.start(out)
+		err := <-s.done
+		if werr != nil {
+			err = werr
+		}
+		<-free
+
+		if !done(i, err) {
+			// the files already started are let finish without output
+			for _, s := range slots[i+1:] {
+				s.start(ioutil.Discard)
+			}
+			return
+		}
+	}
+}
+
+// outputSlot holds the output of a file until it's started, and passes it
+// on to out from then on. Writes wait for the start once slotLimit is held.
+type outputSlot struct {
+	mu      sync.Mutex
+	started *sync.Cond
+	out     io.Writer
+	buf     bytes.Buffer
+	done    chan error
+}
+
+func newOutputSlot() *outputSlot {
+	s := &outputSlot{done: make(chan error, 1)}
+	s.started = sync.NewCond(&s.mu)
+
+	return s
+}
+
+func (s *outputSlot) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.out == nil && s.buf.Len()+len(p) > slotLimit {
+		s.started.Wait()
+	}
+	if s.out != nil {
+		return s.out.Write(p)
+	}
+
+	return s.buf.Write(p)
+}
+
+// start writes what's been buffered to out and lets what's printed later
+// through.
+func (s *outputSlot) start(out io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.out = out
+	_, err := s.buf.WriteTo(out)
+	s.buf = bytes.Buffer{}
+	s.started.Broadcast()
+
+	return err
+}
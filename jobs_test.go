@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrintOrdered(t *testing.T) {
+	for _, jobs := range []int{1, 3, 10} {
+		var (
+			w              bytes.Buffer
+			mu             sync.Mutex
+			active, most   int
+			errs, expected []string
+		)
+
+		printOrdered(10, jobs, &w, func(i int, w io.Writer) error {
+			mu.Lock()
+			if active++; active > most {
+				most = active
+			}
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				active--
+				mu.Unlock()
+			}()
+
+			// later files are done first
+			for j := 0; j < 3; j++ {
+				time.Sleep(time.Duration(10-i) * time.Millisecond / 2)
+				fmt.Fprintf(w, "%d.%d\n", i, j)
+			}
+			if i%4 == 1 {
+				return errors.New(fmt.Sprint(i))
+			}
+			return nil
+		}, func(i int, err error) bool {
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			return true
+		})
+
+		for i := 0; i < 10; i++ {
+			expected = append(expected, fmt.Sprintf("%d.0\n%d.1\n%d.2\n", i, i, i))
+		}
+		if w.String() != strings.Join(expected, "") {
+			t.Errorf("Jobs: %d\n\noutput is wrong: %q", jobs, w.String())
+		}
+		if strings.Join(errs, " ") != "1 5 9" {
+			t.Errorf("Jobs: %d\n\nerrors are wrong: %q", jobs, errs)
+		}
+		if most > jobs {
+			t.Errorf("Jobs: %d\n\n%d files were printed at once", jobs, most)
+		}
+	}
+}
+
+func TestPrintOrderedStop(t *testing.T) {
+	var w bytes.Buffer
+	var printed []int
+	var mu sync.Mutex
+	printOrdered(100, 2, &w, func(i int, w io.Writer) error {
+		mu.Lock()
+		printed = append(printed, i)
+		mu.Unlock()
+		fmt.Fprintf(w, "%d\n", i)
+		return nil
+	}, func(i int, err error) bool {
+		return i < 2
+	})
+
+	if w.String() != "0\n1\n2\n" {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+
+	// the files after the one that stops the printing are left alone,
+	// but for those already started
+	mu.Lock()
+	defer mu.Unlock()
+	if len(printed) > 5 {
+		t.Errorf("too many files are printed: %v", printed)
+	}
+}
+
+func TestPrintOrderedLimit(t *testing.T) {
+	var w bytes.Buffer
+	finished := make(chan int, 1)
+	chunk := bytes.Repeat([]byte("x"), slotLimit/4)
+
+	printOrdered(2, 2, &w, func(i int, w io.Writer) error {
+		if i == 0 {
+			// the second file waits once it holds the limit
+			time.Sleep(50 * time.Millisecond)
+			select {
+			case <-finished:
+				t.Errorf("output of the second file isn't limited")
+			default:
+			}
+			return nil
+		}
+
+		for j := 0; j < 8; j++ {
+			w.Write(chunk)
+		}
+		finished <- i
+		return nil
+	}, func(i int, err error) bool {
+		return true
+	})
+
+	if w.Len() != 2*slotLimit {
+		t.Errorf("output is wrong: %d bytes", w.Len())
+	}
+}
+
+// writeTestTree writes n Go files of about size bytes each to a new
+// directory and returns their names.
+func writeTestTree(b *testing.B, n, size int) (string, []string) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var src bytes.Buffer
+	for i := 0; src.Len() < size; i++ {
+		fmt.Fprintf(&src, "// F%d returns %d.\nfunc F%d(s string) (int, error) {\n\tif s == \"%d\" {\n\t\treturn %d, nil /* ok */\n\t}\n\treturn 0, errors.New(`no`)\n}\n\n", i, i, i, i, i)
+	}
+
+	var names []string
+	for i := 0; i < n; i++ {
+		fname := filepath.Join(dir, fmt.Sprintf("pkg%d", i%10), fmt.Sprintf("f%d.go", i))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, src.Bytes(), 0644); err != nil {
+			b.Fatal(err)
+		}
+		names = append(names, fname)
+	}
+
+	return dir, names
+}
+
+// BenchmarkCCatJobs highlights a tree of 200 files of 20 KB with numbers
+// and whitespace shown, a file at a time and with as many jobs as CPUs.
+func BenchmarkCCatJobs(b *testing.B) {
+	dir, names := writeTestTree(b, 200, 20<<10)
+	defer os.RemoveAll(dir)
+
+	jobs := []int{1, 2, 4}
+	if n := runtime.NumCPU(); n > 4 {
+		jobs = append(jobs, n)
+	}
+	for _, j := range jobs {
+		b.Run(fmt.Sprintf("jobs=%d", j), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				printOrdered(len(names), j, ioutil.Discard, func(i int, w io.Writer) error {
+					var p CCatPrinter = ColorPrinter{LightColorPalettes}
+					p = &GutterPrinter{Printer: p, Numbers: true}
+					p = &WhitespacePrinter{Printer: p, Tabs: 4, Show: true}
+					return CCat(names[i], p, w)
+				}, func(i int, err error) bool {
+					if err != nil {
+						b.Fatal(err)
+					}
+					return true
+				})
+			}
+		})
+	}
+}
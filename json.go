@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// jsonToken is a token in the JSON Lines output of JsonTokensPrint.
+// Offset is in bytes from the start of the input, Line and Column
+// start at 1 and Column counts characters. Input transcoded with
+// --encoding is counted in its UTF-8 bytes.
+type jsonToken struct {
+	Kind   string `json:"kind"`
+	Text   string `json:"text"`
+	Offset int    `json:"offset"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func JsonTokensPrint(src TokenSource, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	p := &JsonTokensCodePrinter{enc: enc, line: 1, column: 1}
+
+	return src(w, p)
+}
+
+type JsonTokensCodePrinter struct {
+	enc    *json.Encoder
+	offset int
+	line   int
+	column int
+}
+
+func (p *JsonTokensCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	err := p.enc.Encode(jsonToken{
+		Kind:   kindName(kind),
+		Text:   tokText,
+		Offset: p.offset,
+		Line:   p.line,
+		Column: p.column,
+	})
+	if err != nil {
+		return err
+	}
+
+	p.offset += len(tokText)
+	for len(tokText) > 0 {
+		r, size := utf8.DecodeRuneInString(tokText)
+		tokText = tokText[size:]
+
+		if r == '\n' {
+			p.line++
+			p.column = 1
+		} else {
+			p.column++
+		}
+	}
+
+	return nil
+}
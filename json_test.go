@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestJsonTokensPrint(t *testing.T) {
+	r := bytes.NewBufferString("x := \"é<\"\ny")
+	var w bytes.Buffer
+
+	err := JsonTokensPrint(Lex(r), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expect := `{"kind":"Plaintext","text":"x","offset":0,"line":1,"column":1}
+{"kind":"Whitespace","text":" ","offset":1,"line":1,"column":2}
+{"kind":"Punctuation","text":":","offset":2,"line":1,"column":3}
+{"kind":"Punctuation","text":"=","offset":3,"line":1,"column":4}
+{"kind":"Whitespace","text":" ","offset":4,"line":1,"column":5}
+{"kind":"String","text":"\"é<\"","offset":5,"line":1,"column":6}
+{"kind":"Whitespace","text":"\n","offset":10,"line":1,"column":10}
+{"kind":"Plaintext","text":"y","offset":11,"line":2,"column":1}
+`
+
+	s := w.String()
+	if s != expect {
+		t.Errorf("output is wrong: %s", s)
+	}
+}
+
+func TestJsonTokensOffsets(t *testing.T) {
+	cases := []struct {
+		Printer  CCatPrinter
+		Expected string
+	}{
+		// the offsets count the bytes of the input
+		{JsonTokensPrinter{}, `{"kind":"Plaintext","text":"y","offset":10,"line":2,"column":1}`},
+		// and those of the UTF-8 text once it's transcoded
+		{&EncodingPrinter{Printer: JsonTokensPrinter{}, Encoding: "windows-1252"}, `{"kind":"Plaintext","text":"y","offset":11,"line":2,"column":1}`},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		err := tc.Printer.Print(bytes.NewBufferString("x := \"\xe9<\"\ny"), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(w.String()), "\n")
+		if last := lines[len(lines)-1]; last != tc.Expected {
+			t.Errorf("Printer: %T\n\nOutput: %s\n\nExpected: %s", tc.Printer, last, tc.Expected)
+		}
+	}
+}
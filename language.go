@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+var languagesByExt = map[string]string{
+	".c":          "C",
+	".h":          "C",
+	".cc":         "C++",
+	".cpp":        "C++",
+	".cxx":        "C++",
+	".hpp":        "C++",
+	".cs":         "C#",
+	".css":        "CSS",
+	".go":         "Go",
+	".htm":        "HTML",
+	".html":       "HTML",
+	".java":       "Java",
+	".js":         "JavaScript",
+	".jsx":        "JavaScript",
+	".mjs":        "JavaScript",
+	".json":       "JSON",
+	".kt":         "Kotlin",
+	".lua":        "Lua",
+	".md":         "Markdown",
+	".markdown":   "Markdown",
+	".m":          "Objective-C",
+	".php":        "PHP",
+	".pl":         "Perl",
+	".pm":         "Perl",
+	".py":         "Python",
+	".rb":         "Ruby",
+	".rs":         "Rust",
+	".scala":      "Scala",
+	".sh":         "Shell",
+	".bash":       "Shell",
+	".zsh":        "Shell",
+	".sql":        "SQL",
+	".swift":      "Swift",
+	".tex":        "TeX",
+	".toml":       "TOML",
+	".ts":         "TypeScript",
+	".tsx":        "TypeScript",
+	".xml":        "XML",
+	".svg":        "XML",
+	".yaml":       "YAML",
+	".yml":        "YAML",
+	".dockerfile": "Dockerfile",
+}
+
+var languagesByName = map[string]string{
+	"Dockerfile":  "Dockerfile",
+	"Makefile":    "Makefile",
+	"GNUmakefile": "Makefile",
+	"Gemfile":     "Ruby",
+	"Rakefile":    "Ruby",
+	"_ccat":       "Shell",
+}
+
+var languagesByInterpreter = map[string]string{
+	"bash":    "Shell",
+	"sh":      "Shell",
+	"zsh":     "Shell",
+	"node":    "JavaScript",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"python":  "Python",
+	"python2": "Python",
+	"python3": "Python",
+	"ruby":    "Ruby",
+}
+
+// detectLanguage guesses the language of a file from its name, or from
+// the #! line at the start of head. The name of a compressed file or of
+// an archive member is that of the file inside. It returns "" if it can't
+// tell.
+func detectLanguage(fname string, head []byte) string {
+	if _, member, ok := splitArchiveMember(fname); ok {
+		fname = member
+	}
+	base := filepath.Base(decompressedName(fname))
+	if l, ok := languagesByName[base]; ok {
+		return l
+	}
+	if l, ok := languagesByExt[strings.ToLower(filepath.Ext(base))]; ok {
+		return l
+	}
+
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return ""
+	}
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+
+	// #!/usr/bin/env python3 or #!/bin/sh -e
+	fields := strings.Fields(string(head[2:]))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" && len(fields) > 1 {
+		interp = fields[1]
+	}
+
+	return languagesByInterpreter[interp]
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// LatexPreamble writes the packages, colors and macros used by the
+// output of LatexPrint to w.
+func LatexPreamble(w io.Writer, palettes ColorPalettes) error {
+	var b bytes.Buffer
+	b.WriteString("\\usepackage{fancyvrb}\n\\usepackage{xcolor}\n")
+	b.WriteString("\\newcommand{\\ccatZbs}{\\char`\\\\}\n")
+	b.WriteString("\\newcommand{\\ccatZob}{\\char`\\{}\n")
+	b.WriteString("\\newcommand{\\ccatZcb}{\\char`\\}}\n")
+
+	for _, k := range kinds {
+		s := parseStyle(palettes[k])
+		body := "#1"
+		if s.Bold {
+			body = fmt.Sprintf("\\textbf{%s}", body)
+		}
+		if s.Underline {
+			body = fmt.Sprintf("\\underline{%s}", body)
+		}
+		if c, ok := rgbCodes[s.Color]; ok {
+			fmt.Fprintf(&b, "\\definecolor{%s}{HTML}{%s}\n", latexMacro(k), strings.ToUpper(hexColor(c)[1:]))
+			body = fmt.Sprintf("\\textcolor{%s}{%s}", latexMacro(k), body)
+		}
+		fmt.Fprintf(&b, "\\newcommand{\\%s}[1]{%s}\n", latexMacro(k), body)
+	}
+
+	_, err := b.WriteTo(w)
+
+	return err
+}
+
+func LatexPrint(src TokenSource, w io.Writer, palettes ColorPalettes) error {
+	_, err := io.WriteString(w, "\\begin{Verbatim}[commandchars=\\\\\\{\\}]\n")
+	if err != nil {
+		return err
+	}
+
+	p := &LatexCodePrinter{ColorPalettes: palettes}
+	err = src(w, p)
+	if err != nil {
+		return err
+	}
+
+	end := "\\end{Verbatim}\n"
+	if p.col > 0 {
+		end = "\n" + end
+	}
+	_, err = io.WriteString(w, end)
+
+	return err
+}
+
+// LatexCodePrinter prints tokens as arguments of the macros defined by
+// LatexPreamble. It expands tabs itself since fancyvrb doesn't handle
+// them reliably together with commandchars.
+type LatexCodePrinter struct {
+	ColorPalettes ColorPalettes
+
+	col int
+}
+
+func (p *LatexCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	macro := ""
+	if c := p.ColorPalettes.Get(kind); len(c) > 0 {
+		macro = latexMacro(kindsByKind[kind])
+	}
+
+	var b bytes.Buffer
+	for i, line := range strings.Split(tokText, "\n") {
+		if i > 0 {
+			b.WriteByte('\n')
+			p.col = 0
+		}
+
+		text := p.escape(line)
+		if macro != "" && text != "" {
+			text = fmt.Sprintf("\\%s{%s}", macro, text)
+		}
+		b.WriteString(text)
+	}
+
+	_, err := b.WriteTo(w)
+
+	return err
+}
+
+// escape escapes the characters that have a meaning inside Verbatim
+// with commandchars and expands tabs.
+func (p *LatexCodePrinter) escape(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString("\\ccatZbs{}")
+		case '{':
+			b.WriteString("\\ccatZob{}")
+		case '}':
+			b.WriteString("\\ccatZcb{}")
+		case '\t':
+			n := tabStop(p.col, defaultTabWidth)
+			b.WriteString(strings.Repeat(" ", n))
+			p.col += n
+			continue
+		case '\r':
+			continue
+		default:
+			b.WriteRune(r)
+		}
+		p.col += runeWidth(r)
+	}
+
+	return b.String()
+}
+
+func latexMacro(k kind) string {
+	return "ccat" + k.Name
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLatexPrint(t *testing.T) {
+	r := bytes.NewBufferString("a\t{\"\\\\\"}\n")
+	var w bytes.Buffer
+
+	err := LatexPrint(Lex(r), &w, LightColorPalettes)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expect := `\begin{Verbatim}[commandchars=\\\{\}]
+\ccatPlaintext{a}       \ccatPunctuation{\ccatZob{}}\ccatString{"\ccatZbs{}\ccatZbs{}"}\ccatPunctuation{\ccatZcb{}}
+\end{Verbatim}
+`
+
+	s := w.String()
+	if s != expect {
+		t.Errorf("output is wrong: %s", s)
+	}
+}
+
+func TestLatexPreamble(t *testing.T) {
+	var w bytes.Buffer
+
+	palettes := ColorPalettes{
+		keywordKind: "*darkblue*",
+	}
+	err := LatexPreamble(&w, palettes)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	s := w.String()
+	for _, line := range []string{
+		"\\definecolor{ccatKeyword}{HTML}{3465A4}\n",
+		"\\newcommand{\\ccatKeyword}[1]{\\textcolor{ccatKeyword}{\\textbf{#1}}}\n",
+		"\\newcommand{\\ccatString}[1]{#1}\n",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("preamble should contain %q: %s", line, s)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// LineRange is an inclusive range of line numbers. An End of 0 means the
+// range reaches the end of the file.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+func (r LineRange) Contains(line int) bool {
+	return line >= r.Start && (r.End == 0 || line <= r.End)
+}
+
+// parseLineRange parses a range of --line-range, which is N:M, N:, :M,
+// N:+K for K lines after N or a single line N.
+func parseLineRange(s string) (LineRange, error) {
+	var r LineRange
+	invalid := fmt.Errorf("invalid line range: %s", s)
+	if s == "" {
+		return r, invalid
+	}
+
+	start, end := s, s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		start, end = s[:i], s[i+1:]
+	}
+
+	r.Start = 1
+	if start != "" {
+		n, err := strconv.Atoi(start)
+		if err != nil || n < 1 {
+			return r, invalid
+		}
+		r.Start = n
+	}
+
+	if strings.HasPrefix(end, "+") {
+		n, err := strconv.Atoi(end[1:])
+		if err != nil || n < 0 {
+			return r, invalid
+		}
+		r.End = r.Start + n
+	} else if end != "" {
+		n, err := strconv.Atoi(end)
+		if err != nil || n < r.Start {
+			return r, invalid
+		}
+		r.End = n
+	}
+
+	return r, nil
+}
+
+// parseLineRanges parses the values of --line-range, each of which may
+// hold several comma separated ranges.
+func parseLineRanges(values []string) ([]LineRange, error) {
+	var ranges []LineRange
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			r, err := parseLineRange(strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, r)
+		}
+	}
+
+	return ranges, nil
+}
+
+// FileSpec is a FILE argument, optionally followed by a line and a column
+// as in the file:line:column positions printed by compilers and grep, of
+// which only the line is kept. The name can be ARCHIVE:MEMBER for a file
+// in a tar or zip archive.
+type FileSpec struct {
+	Name string
+	Line int
+}
+
+// parseFileSpec splits the position off arg. A file whose name really
+// ends in :digits is taken as is.
+func parseFileSpec(arg string) FileSpec {
+	spec := FileSpec{Name: arg}
+	if arg == readFromStdin {
+		return spec
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return spec
+	}
+
+	var nums []int
+	name := arg
+	for len(nums) < 2 {
+		i := strings.LastIndexByte(name, ':')
+		if i <= 0 {
+			break
+		}
+		n, err := strconv.Atoi(name[i+1:])
+		if err != nil || n < 1 {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		name = name[:i]
+	}
+
+	if len(nums) > 0 {
+		spec.Name = name
+		spec.Line = nums[0]
+	}
+
+	return spec
+}
+
+// Window returns the range of the target line with context lines around.
+func (s FileSpec) Window(context int) LineRange {
+	start := s.Line - context
+	if start < 1 {
+		start = 1
+	}
+
+	return LineRange{Start: start, End: s.Line + context}
+}
+
+// errLinesDone stops lexing once the last selected line was printed.
+var errLinesDone = errors.New("no more selected lines")
+
+// RangePrinter only prints the lines within Ranges, or all lines if there
+// are none. The whole file is still lexed from the top, so tokens spanning
+// several lines are highlighted correctly within the selection.
+//
+// If Target is set, its line is emphasized by a marker of the TargetLine
+// kind and the other lines are indented to keep them aligned.
+type RangePrinter struct {
+	Printer CCatPrinter
+	Ranges  []LineRange
+	Target  int
+
+	line    int
+	midLine bool
+}
+
+func (r *RangePrinter) SetFile(file File) {
+	r.line = 0
+	r.midLine = false
+
+	if f, ok := r.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (r *RangePrinter) Print(rd io.Reader, w io.Writer) error {
+	return r.PrintTokens(Lex(rd), w)
+}
+
+func (r *RangePrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(r.Printer, func(w io.Writer, p syntaxhighlight.Printer) error {
+		err := src(w, rangeCodePrinter{r, p})
+		if err == errLinesDone {
+			return nil
+		}
+		return err
+	}, w)
+}
+
+// selected reports whether line is printed.
+func (r *RangePrinter) selected(line int) bool {
+	if len(r.Ranges) == 0 {
+		return true
+	}
+
+	for _, lr := range r.Ranges {
+		if lr.Contains(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// done reports whether no line after line is printed.
+func (r *RangePrinter) done(line int) bool {
+	if len(r.Ranges) == 0 {
+		return false
+	}
+
+	for _, lr := range r.Ranges {
+		if lr.End == 0 || lr.End > line {
+			return false
+		}
+	}
+
+	return true
+}
+
+type rangeCodePrinter struct {
+	*RangePrinter
+	p syntaxhighlight.Printer
+}
+
+func (r rangeCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	for len(tokText) > 0 {
+		if !r.midLine {
+			r.midLine = true
+			r.line++
+			if r.done(r.line - 1) {
+				return errLinesDone
+			}
+			if r.Target > 0 && r.selected(r.line) {
+				if err := r.printMarker(w); err != nil {
+					return err
+				}
+			}
+		}
+
+		text := tokText
+		i := strings.IndexByte(tokText, '\n')
+		if i >= 0 {
+			text = tokText[:i+1]
+			r.midLine = false
+		}
+		tokText = tokText[len(text):]
+
+		if r.selected(r.line) {
+			if err := r.p.Print(w, kind, text); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r rangeCodePrinter) printMarker(w io.Writer) error {
+	if r.line != r.Target {
+		return r.p.Print(w, syntaxhighlight.Whitespace, "  ")
+	}
+
+	if err := r.p.Print(w, TargetLine, ">"); err != nil {
+		return err
+	}
+
+	return r.p.Print(w, syntaxhighlight.Whitespace, " ")
+}
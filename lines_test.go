@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseLineRange(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected LineRange
+	}{
+		{"40:80", LineRange{40, 80}},
+		{"40:", LineRange{40, 0}},
+		{":80", LineRange{1, 80}},
+		{"40:+5", LineRange{40, 45}},
+		{"40", LineRange{40, 40}},
+	}
+
+	for _, tc := range cases {
+		r, err := parseLineRange(tc.Input)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+		if r != tc.Expected {
+			t.Errorf("Input: %s\n\nOutput: %v\n\nExpected: %v", tc.Input, r, tc.Expected)
+		}
+	}
+
+	for _, s := range []string{"", "a:b", "80:40", "0:1", "1:+x"} {
+		if _, err := parseLineRange(s); err == nil {
+			t.Errorf("error should not be nil for %q", s)
+		}
+	}
+}
+
+func TestParseFileSpec(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected FileSpec
+	}{
+		{"main.go", FileSpec{"main.go", 0}},
+		{"main.go:42", FileSpec{"main.go", 42}},
+		{"main.go:42:10", FileSpec{"main.go", 42}},
+		{"a:b:42", FileSpec{"a:b", 42}},
+		{"main.go:x", FileSpec{"main.go:x", 0}},
+		{":42", FileSpec{":42", 0}},
+		{"-", FileSpec{"-", 0}},
+	}
+
+	for _, tc := range cases {
+		spec := parseFileSpec(tc.Input)
+		if !reflect.DeepEqual(spec, tc.Expected) {
+			t.Errorf("Input: %s\n\nOutput: %v\n\nExpected: %v", tc.Input, spec, tc.Expected)
+		}
+	}
+}
+
+func TestRangePrinter(t *testing.T) {
+	input := "a\n/* b\nc */\nd\ne\nf\n"
+	cases := []struct {
+		Ranges []LineRange
+		Target int
+		Output string
+	}{
+		{
+			Ranges: nil,
+			Output: "a\n\033[30;01m/* b\n\033[39;49;00m\033[30;01mc */\033[39;49;00m\nd\ne\nf\n",
+		},
+		{
+			Ranges: []LineRange{{3, 4}},
+			Output: "\033[30;01mc */\033[39;49;00m\nd\n",
+		},
+		{
+			Ranges: []LineRange{{1, 1}, {5, 0}},
+			Output: "a\ne\nf\n",
+		},
+		{
+			Ranges: []LineRange{{3, 5}},
+			Target: 4,
+			Output: "  \033[30;01mc */\033[39;49;00m\n\033[30;01m>\033[39;49;00m d\n  e\n",
+		},
+	}
+
+	palettes := ColorPalettes{commentKind: "darkgray", targetLineKind: "darkgray"}
+	for _, tc := range cases {
+		var w bytes.Buffer
+		p := &RangePrinter{Printer: ColorPrinter{palettes}, Ranges: tc.Ranges, Target: tc.Target}
+		p.SetFile(File{Name: readFromStdin})
+
+		err := p.Print(bytes.NewBufferString(input), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		if w.String() != tc.Output {
+			t.Errorf("Ranges: %v\n\nOutput: %q\n\nExpected: %q", tc.Ranges, w.String(), tc.Output)
+		}
+	}
+}
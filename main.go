@@ -2,10 +2,17 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"os"
+	"runtime"
+	"syscall"
 
 	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const (
@@ -13,15 +20,63 @@ const (
 )
 
 type ccatCmd struct {
-	BG          string
-	Color       string
-	ColorCodes  mapValue
-	HTML        bool
-	ShowPalette bool
-	ShowVersion bool
+	BG             string
+	Color          string
+	ColorCodes     mapValue
+	Format         string
+	HTML           bool
+	RTF            bool
+	LaTeX          bool
+	LaTeXPre       bool
+	SVG            bool
+	PNG            string
+	Background     string
+	Frame          bool
+	Padding        int
+	Scale          int
+	ShowPalette    bool
+	ShowVersion    bool
+	FromTokens     bool
+	ShowAll        bool
+	Cat            CatOptions
+	Style          string
+	Decorations    string
+	Paging         string
+	Wrap           string
+	TerminalWidth  int
+	Tabs           int
+	ShowWhitespace bool
+	Binary         string
+	RawControl     bool
+	ANSI           string
+	FromANSI       bool
+	Encoding       string
+	List           bool
+	Recursive      bool
+	Include        []string
+	Exclude        []string
+	MaxDepth       int
+	Jobs           int
+	Follow         bool
+	Tail           int
+	LineRanges     []string
+	Context        int
 }
 
 func (c *ccatCmd) Run(cmd *cobra.Command, args []string) {
+	c.run(args, CCat)
+}
+
+// Render is like Run, but reads token streams with --from-tokens.
+func (c *ccatCmd) Render(cmd *cobra.Command, args []string) {
+	if c.FromTokens {
+		c.run(args, CCatTokens)
+	} else {
+		c.run(args, CCat)
+	}
+}
+
+func (c *ccatCmd) run(args []string, cat func(string, CCatPrinter, io.Writer) error) {
 	stdout := colorable.NewColorableStdout()
 
 	if c.ShowVersion {
@@ -61,32 +116,388 @@ Value of color can be %s
 		return
 	}
 
+	if c.LaTeXPre {
+		err := LatexPreamble(stdout, colorPalettes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// if there's no args, read from stdin
+	if len(args) == 0 {
+		args = []string{readFromStdin}
+	}
+
+	ranges, err := parseLineRanges(c.LineRanges)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	specs := make([]FileSpec, len(args))
+	selectLines := len(ranges) > 0
+	for i, arg := range args {
+		specs[i] = parseFileSpec(arg)
+		if specs[i].Line > 0 {
+			selectLines = true
+		}
+	}
+
+	// the exit status is 1 if any file couldn't be printed, like cat's
+	status := 0
+
+	// directories are replaced by the files in them
+	if c.Recursive {
+		if c.MaxDepth < 0 {
+			log.Fatal(fmt.Errorf("invalid max depth: %d", c.MaxDepth))
+		}
+		walk := WalkOptions{Include: c.Include, Exclude: c.Exclude, MaxDepth: c.MaxDepth}
+
+		var files []FileSpec
+		for _, spec := range specs {
+			if fi, err := os.Stat(spec.Name); err != nil || !fi.IsDir() {
+				files = append(files, spec)
+				continue
+			}
+
+			names, err := WalkFiles(spec.Name, walk)
+			if _, ok := err.(*FileError); ok {
+				log.Print(err)
+				status = 1
+			} else if err != nil {
+				log.Fatal(err)
+			}
+			for _, name := range names {
+				files = append(files, FileSpec{Name: name})
+			}
+		}
+		specs = files
+	}
+
+	if c.Tail < -1 {
+		log.Fatal(fmt.Errorf("invalid number of lines: %d", c.Tail))
+	}
+	if c.Follow && len(specs) > 1 {
+		log.Fatal(fmt.Errorf("--follow accepts a single FILE"))
+	}
+
+	var out io.Writer = stdout
+	if c.PNG != "" {
+		if len(specs) > 1 {
+			log.Fatal(fmt.Errorf("--png accepts a single FILE"))
+		}
+
+		f, err := os.Create(c.PNG)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	var pager *Pager
+	switch c.Paging {
+	case "always":
+		pager = &Pager{Command: pagerCommand(), Out: stdout}
+	case "never":
+	case "auto":
+		// a followed file never ends, so it isn't paged
+		if c.PNG == "" && !c.Follow && isatty.IsTerminal(uintptr(syscall.Stdout)) {
+			pager = &Pager{Command: pagerCommand(), Height: terminalHeight(), Out: stdout}
+		}
+	default:
+		log.Fatal(fmt.Errorf("unknown paging mode: %s", c.Paging))
+	}
+	if pager != nil {
+		out = pager
+	}
+
+	// --ansi=html converts the colors of the input to html unless svg or
+	// png is asked for
+	if c.ANSI == ANSIHTML && !c.SVG && c.PNG == "" {
+		c.HTML = true
+	}
+
 	var printer CCatPrinter
-	if c.HTML {
+	// plain output isn't colored, so the colors of the input are kept as
+	// they are
+	plain := false
+	// json tokens are taken from the bytes of the input so that their
+	// offsets point into it
+	tokens := c.Format == "json-tokens"
+	if tokens {
+		printer = JsonTokensPrinter{}
+		plain = true
+	} else if c.Format != "" {
+		log.Fatal(fmt.Errorf("unknown format: %s", c.Format))
+	} else if c.PNG != "" {
+		// png output numbers all lines in a gutter of its own rather than
+		// like cat -n
+		lineNumbers := c.Cat.Number && !c.Cat.NumberNonblank
+		if lineNumbers {
+			c.Cat.Number = false
+		}
+		printer = PngPrinter{
+			ColorPalettes: colorPalettes,
+			BG:            c.BG,
+			Background:    c.Background,
+			LineNumbers:   lineNumbers,
+			Padding:       c.Padding,
+			Scale:         c.Scale,
+		}
+	} else if c.HTML {
 		printer = HtmlPrinter{colorPalettes}
+	} else if c.RTF {
+		printer = RtfPrinter{colorPalettes}
+	} else if c.LaTeX {
+		printer = LatexPrinter{colorPalettes}
+	} else if c.SVG {
+		printer = SvgPrinter{
+			ColorPalettes: colorPalettes,
+			BG:            c.BG,
+			Background:    c.Background,
+			Frame:         c.Frame,
+			Padding:       c.Padding,
+		}
 	} else if c.Color == "always" {
 		printer = ColorPrinter{colorPalettes}
 	} else if c.Color == "never" {
 		printer = PlainTextPrinter{}
+		plain = true
 	} else {
 		printer = AutoColorPrinter{colorPalettes}
+		plain = !isatty.IsTerminal(uintptr(syscall.Stdout))
 	}
 
-	// if there's no args, read from stdin
-	if len(args) == 0 {
-		args = []string{readFromStdin}
+	decorations, err := parseDecorations(c.Style)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch c.Decorations {
+	case "always":
+	case "never":
+		decorations = Decorations{}
+	case "auto":
+		if !isatty.IsTerminal(uintptr(syscall.Stdout)) {
+			decorations = Decorations{}
+		}
+	default:
+		log.Fatal(fmt.Errorf("unknown decorations mode: %s", c.Decorations))
+	}
+
+	// the files found in directories are told apart by their headers
+	if c.Recursive && c.Decorations != "never" {
+		decorations.Header = true
+	}
+
+	if c.ShowAll {
+		c.Cat.ShowNonprinting = true
+		c.Cat.ShowEnds = true
+		c.Cat.ShowTabs = true
+	}
+
+	width := c.TerminalWidth
+	if width <= 0 {
+		width = terminalWidth()
+	}
+
+	// lines are wrapped after everything else has been added to them
+	wrap, wrapWords := false, false
+	switch c.Wrap {
+	case "never":
+	case "character", "word":
+		wrap, wrapWords = true, c.Wrap == "word"
+	case "auto":
+		// json tokens are lines of their own that aren't wrapped
+		wrap, wrapWords = !tokens && isatty.IsTerminal(uintptr(syscall.Stdout)), true
+	default:
+		log.Fatal(fmt.Errorf("unknown wrap mode: %s", c.Wrap))
 	}
 
-	for _, arg := range args {
-		err := CCat(arg, printer, stdout)
+	if c.Tabs < 0 {
+		log.Fatal(fmt.Errorf("invalid tab width: %d", c.Tabs))
+	}
+
+	ansi := c.ANSI
+	switch {
+	case c.FromANSI:
+		ansi = ANSIPreserve
+	case c.ANSI == ANSIAuto, c.ANSI == ANSIPreserve, c.ANSI == ANSIStrip, c.ANSI == ANSIHTML:
+	default:
+		log.Fatal(fmt.Errorf("unknown ansi mode: %s", c.ANSI))
+	}
+
+	binary := c.Binary
+	if binary == "auto" {
+		binary = BinaryRaw
+		if isatty.IsTerminal(uintptr(syscall.Stdout)) {
+			binary = BinaryNotice
+		}
+	}
+	switch binary {
+	case BinaryNotice, BinaryHex, BinaryRaw:
+	default:
+		log.Fatal(fmt.Errorf("unknown binary mode: %s", c.Binary))
+	}
+
+	// piped output that isn't colored is left as it is like cat's, and so
+	// are json tokens, unless an encoding is given
+	encoding := c.Encoding
+	if encoding == "" && !tokens && (!plain || isatty.IsTerminal(uintptr(syscall.Stdout))) {
+		encoding = EncodingAuto
+	}
+	if encoding != "" && encoding != EncodingAuto && encodingName(encoding) == "" {
+		log.Fatal(fmt.Errorf("unknown encoding: %s", c.Encoding))
+	}
+
+	// cat numbers and squeezes lines across files, so they're printed one
+	// after another with the same CatPrinter then
+	jobs := c.Jobs
+	if jobs < 0 {
+		log.Fatal(fmt.Errorf("invalid number of jobs: %d", jobs))
+	}
+	if jobs == 0 {
+		jobs = runtime.NumCPU()
+	}
+	var catPrinter *CatPrinter
+	if c.Cat.Number || c.Cat.NumberNonblank || c.Cat.SqueezeBlank {
+		jobs = 1
+		catPrinter = &CatPrinter{Options: c.Cat}
+	}
+
+	// a rule is drawn between the files that are printed, so they're
+	// printed one after another with the same FramePrinter then
+	var framePrinter *FramePrinter
+	if decorations.Rule && !decorations.Grid {
+		jobs = 1
+		framePrinter = &FramePrinter{Printer: printer, Decorations: decorations, Width: width}
+	}
+
+	// newPrinter returns the printers of a file, which are its own so that
+	// files can be printed concurrently, but for the shared ones above
+	newPrinter := func(spec FileSpec) CCatPrinter {
+		printer := printer
+
+		// the frame wraps the output printer directly, so its lines are
+		// neither selected nor numbered
+		if framePrinter != nil {
+			printer = framePrinter
+		} else if decorations.Frame() {
+			printer = &FramePrinter{Printer: printer, Decorations: decorations, Width: width}
+		}
+
+		// the target line is emphasized in the gutter if there's one
+		gutter := decorations.Gutter()
+		if wrap {
+			targetMarker := spec.Line > 0 && !gutter
+			indent, bar := lineIndent(decorations, c.Cat, targetMarker)
+			printer = &WrapPrinter{Printer: printer, Width: width, Words: wrapWords, Indent: indent, Bar: bar}
+		}
+
+		// lines are selected after the other printers have numbered them
+		if selectLines {
+			rangePrinter := &RangePrinter{Printer: printer, Ranges: ranges}
+			if spec.Line > 0 {
+				rangePrinter.Ranges = []LineRange{spec.Window(c.Context)}
+			}
+			if !gutter {
+				rangePrinter.Target = spec.Line
+			}
+			printer = rangePrinter
+		}
+
+		if gutter {
+			gutterPrinter := &GutterPrinter{
+				Printer: printer,
+				Numbers: decorations.Numbers,
+				Changes: decorations.Changes,
+			}
+			if selectLines {
+				gutterPrinter.Target = spec.Line
+			}
+			printer = gutterPrinter
+		}
+
+		if catPrinter != nil {
+			catPrinter.Printer = printer
+			printer = catPrinter
+		} else if c.Cat.Enabled() {
+			printer = &CatPrinter{Printer: printer, Options: c.Cat}
+		}
+
+		// whitespace is handled before anything is added to the lines
+		if c.Tabs > 0 || c.ShowWhitespace {
+			printer = &WhitespacePrinter{Printer: printer, Tabs: c.Tabs, Show: c.ShowWhitespace}
+		}
+
+		// control characters are made harmless before the other printers
+		// count their columns; json escapes them itself
+		if !c.RawControl && !tokens && isatty.IsTerminal(uintptr(syscall.Stdout)) {
+			printer = &ControlPrinter{Printer: printer}
+		}
+
+		// the colors of the input are taken apart from the text before
+		// anything else sees it
+		printer = &ANSIPrinter{Printer: printer, Mode: ansi, Terminal: c.FromANSI, Plain: plain}
+
+		// binary files are replaced before anything else sees them
+		if binary != BinaryRaw {
+			printer = &BinaryPrinter{Printer: printer, Mode: binary}
+		}
+
+		// text in other encodings is transcoded to UTF-8 before it's told
+		// apart from binary content
+		if encoding == "" {
+			return printer
+		}
+		return &EncodingPrinter{Printer: printer, Encoding: encoding}
+	}
+
+	if c.List {
+		cat = CCatList
+	} else if (c.Follow || c.Tail >= 0) && !c.FromTokens {
+		follower := &Follower{Tail: c.Tail, Follow: c.Follow}
+		cat = follower.CCat
+	}
+
+	printOrdered(len(specs), jobs, out, func(i int, w io.Writer) error {
+		return cat(specs[i].Name, newPrinter(specs[i]), w)
+	}, func(i int, err error) bool {
+		// the output is gone once the pager quits or the reader of a pipe
+		// has had enough, as with head
+		if err == errPagerQuit || isBrokenPipe(err) {
+			return false
+		}
+		if _, ok := err.(*FileError); ok {
+			log.Print(err)
+			status = 1
+			return true
+		}
 		if err != nil {
+			if pager != nil {
+				pager.Close()
+			}
+			log.Fatal(err)
+		}
+
+		return true
+	})
+
+	if pager != nil {
+		if err := pager.Close(); err != nil {
 			log.Fatal(err)
 		}
 	}
+
+	if status != 0 {
+		os.Exit(status)
+	}
 }
 
 func main() {
 	log.SetFlags(0)
+	log.SetPrefix("ccat: ")
 	ccatCmd := &ccatCmd{
 		ColorCodes: make(mapValue),
 	}
@@ -96,7 +507,33 @@ func main() {
 		Example: `$ ccat FILE1 FILE2 ...
   $ ccat --bg=dark FILE1 FILE2 ... # dark background
   $ ccat --html # output html
+  $ ccat --rtf FILE | pbcopy # output rtf
+  $ ccat --latex FILE > code.tex # output latex
+  $ ccat --latex-preamble > preamble.tex # output the preamble for --latex
+  $ ccat --svg --frame FILE > code.svg # output svg with a window frame
+  $ ccat --paging=never FILE # never page the output
+  $ CCAT_PAGER="most" ccat FILE # page with most
+  $ ccat --wrap=character --terminal-width=72 FILE # wrap lines at 72 columns
+  $ ccat --show-whitespace --tabs=4 FILE # show whitespace with tab stops every 4 columns
+  $ ccat --binary=hex image.png # dump a binary file in hex
+  $ ccat --raw-control-chars colored.log # let the escape sequences of a file through
+  $ git log --color | ccat --ansi=strip # highlight colored input without its colors
+  $ ccat --from-ansi --html build.log > build.html # convert a colored CI log to html
+  $ ccat --encoding=shift_jis FILE # transcode a file in shift_jis to utf-8
+  $ ccat release.tar.gz:cmd/main.go # print a file in a tar or zip archive
+  $ ccat --list bundle.zip # print the files in an archive as a tree
+  $ ccat -r --include '*.go' --exclude vendor DIR # print the go files in a directory
+  $ ccat -f --tail 20 app.log # print the last 20 lines of a log and what's appended to it
+  $ ccat -j 8 $(git ls-files) # highlight 8 files at once
+  $ ccat -n FILE # number all output lines
+  $ ccat --style=numbers,changes FILE # show line numbers and git changes
+  $ ccat --style=full FILE1 FILE2 # frame files with a header, a grid and line numbers
+  $ ccat --line-range 40:80 FILE # print lines 40 to 80
+  $ ccat main.go:42 # print line 42 of main.go with the lines around it
+  $ ccat -A FILE # show tabs, line ends and nonprinting characters
+  $ ccat --png code.png -n --scale 3 FILE # render a png with line numbers
   $ ccat -G String="_darkblue_" -G Plaintext="darkred" FILE # set color codes
+  $ ccat --format=json-tokens FILE # output tokens as json lines
   $ ccat --palette # show palette
   $ ccat # read from standard input
   $ curl https://raw.githubusercontent.com/jingweno/ccat/master/main.go | ccat`,
@@ -119,12 +556,98 @@ Examples:
 `
 	rootCmd.SetUsageTemplate(usageTempl)
 
-	rootCmd.PersistentFlags().StringVarP(&ccatCmd.BG, "bg", "", "light", `set to "light" or "dark" depending on the terminal's background`)
-	rootCmd.PersistentFlags().StringVarP(&ccatCmd.Color, "color", "C", "auto", `colorize the output; value can be "never", "always" or "auto"`)
-	rootCmd.PersistentFlags().VarP(&ccatCmd.ColorCodes, "color-code", "G", `set color codes`)
-	rootCmd.PersistentFlags().BoolVarP(&ccatCmd.HTML, "html", "", false, `output html`)
-	rootCmd.PersistentFlags().BoolVarP(&ccatCmd.ShowPalette, "palette", "", false, `show color palettes`)
-	rootCmd.PersistentFlags().BoolVarP(&ccatCmd.ShowVersion, "version", "v", false, `show version`)
+	ccatCmd.addFlags(rootCmd.PersistentFlags())
+
+	renderCmd := &cobra.Command{
+		Use:  "ccat render [OPTION]... [FILE]...",
+		Long: "Render FILE(s), or standard input, with any output format.",
+		Example: `$ ccat --format=json-tokens FILE | ccat render --from-tokens --html
+  $ my-lexer FILE | ccat render --from-tokens --svg > code.svg`,
+		Run: ccatCmd.Render,
+	}
+	renderCmd.SetUsageTemplate(usageTempl)
+	ccatCmd.addFlags(renderCmd.PersistentFlags())
+	renderCmd.Flags().BoolVarP(&ccatCmd.FromTokens, "from-tokens", "", false, `read token streams in the format of --format=json-tokens instead of source code`)
+
+	// render isn't added as a subcommand since cobra would then reject
+	// FILE arguments as unknown commands
+	cmd := rootCmd
+	if args, ok := renderArgs(os.Args[1:]); ok {
+		cmd = renderCmd
+		cmd.SetArgs(args)
+	}
+
+	cmd.Execute()
+}
+
+// renderArgs returns the arguments of ccat render if args, those after
+// the program name, run it: the first of them that isn't a flag or the
+// value of one is render, and there's no file of that name to print.
+func renderArgs(args []string) ([]string, bool) {
+	flags := pflag.NewFlagSet("ccat", pflag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.SetInterspersed(false)
+	(&ccatCmd{ColorCodes: make(mapValue)}).addFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, false
+	}
+
+	rest := flags.Args()
+	if len(rest) == 0 || rest[0] != "render" || flags.ArgsLenAtDash() == 0 {
+		return nil, false
+	}
+	if _, err := os.Stat("render"); err == nil {
+		return nil, false
+	}
+
+	n := len(args) - len(rest)
+	return append(args[:n:n], rest[1:]...), true
+}
 
-	rootCmd.Execute()
+func (c *ccatCmd) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&c.BG, "bg", "", "light", `set to "light" or "dark" depending on the terminal's background`)
+	flags.StringVarP(&c.Color, "color", "C", "auto", `colorize the output; value can be "never", "always" or "auto"`)
+	flags.VarP(&c.ColorCodes, "color-code", "G", `set color codes`)
+	flags.StringVarP(&c.Format, "format", "", "", `output format; value can be "json-tokens" to output the tokens as json lines`)
+	flags.BoolVarP(&c.HTML, "html", "", false, `output html`)
+	flags.BoolVarP(&c.RTF, "rtf", "", false, `output rtf`)
+	flags.BoolVarP(&c.LaTeX, "latex", "", false, `output latex for the fancyvrb package`)
+	flags.BoolVarP(&c.LaTeXPre, "latex-preamble", "", false, `output the latex preamble defining the colors and macros used by --latex`)
+	flags.BoolVarP(&c.SVG, "svg", "", false, `output svg`)
+	flags.StringVarP(&c.PNG, "png", "", "", `render a png image to the given file`)
+	flags.BoolVarP(&c.Frame, "frame", "", false, `draw a window frame around svg output`)
+	flags.IntVarP(&c.Padding, "padding", "", 16, `padding around svg and png output in pixels`)
+	flags.StringVarP(&c.Background, "background", "", "", `background color of svg and png output; a color code or #rrggbb`)
+	flags.IntVarP(&c.Scale, "scale", "", 2, `scale factor of png output`)
+	flags.BoolVarP(&c.ShowPalette, "palette", "", false, `show color palettes`)
+	flags.StringVarP(&c.Style, "style", "", "", `comma separated components to decorate the output with: "header" for the file name, size and language, "grid" for borders, "numbers" for a line number gutter, "changes" for markers of the changes to the git index, "rule" for a line between files, or "full" for all of them`)
+	flags.StringVarP(&c.Decorations, "decorations", "", "auto", `when to draw the --style components; value can be "never", "always" or "auto" for only when standard output is a terminal`)
+	flags.StringArrayVarP(&c.LineRanges, "line-range", "", nil, `only print the lines in the range N:M; N:, :M, N:+K and N are accepted too and the flag can be repeated`)
+	flags.IntVarP(&c.Context, "context", "", 3, `number of lines printed around the line of FILE:LINE[:COLUMN] arguments`)
+	flags.StringVarP(&c.Paging, "paging", "", "auto", `page the output with $CCAT_PAGER, $PAGER or "less -RFX"; value can be "never", "always" or "auto" for only when it doesn't fit in the terminal`)
+	flags.StringVarP(&c.Wrap, "wrap", "", "auto", `wrap long lines; value can be "never", "character", "word" or "auto" for word wrapping only when standard output is a terminal`)
+	flags.IntVarP(&c.TerminalWidth, "terminal-width", "", 0, `width to wrap lines and draw decorations at instead of the width of the terminal`)
+	flags.IntVarP(&c.Tabs, "tabs", "", 0, `expand tabs to spaces with tab stops every N columns; 0 keeps them`)
+	flags.BoolVarP(&c.ShowWhitespace, "show-whitespace", "", false, `show tabs, trailing spaces, non-breaking spaces, zero-width characters, CRs and LFs`)
+	flags.StringVarP(&c.Binary, "binary", "", "auto", `how to print binary files; value can be "notice" for a line with the type of the file, "hex" for a hexdump, "raw" to print them as is or "auto" for a notice only when standard output is a terminal`)
+	flags.BoolVarP(&c.RawControl, "raw-control-chars", "", false, `pass control characters and escape sequences of the input on to the terminal instead of showing them in ^ notation`)
+	flags.StringVarP(&c.ANSI, "ansi", "", "auto", `what to do with the colors of input that's already colored; value can be "preserve" to keep them instead of highlighting, "strip" to remove them before highlighting, "html" to convert them to html, or "auto" to preserve them if the input starts with any; text that is hidden or blinks is shown like the rest`)
+	flags.BoolVarP(&c.FromANSI, "from-ansi", "", false, `convert the output of a program written for a terminal, like a CI log, keeping only its text and colors`)
+	flags.StringVarP(&c.Encoding, "encoding", "", "", `character encoding of the input, which is transcoded to UTF-8; value can be "utf-8", "utf-16le", "utf-16be", "latin1", "windows-1252", "shift_jis" or "auto" to tell it by the byte order mark or the bytes of each file, which is the default unless the output is piped and not colored`)
+	flags.BoolVarP(&c.List, "list", "", false, `print the files in tar and zip archives as a tree instead of their content; ARCHIVE:DIR only prints the files within DIR`)
+	flags.BoolVarP(&c.Recursive, "recursive", "r", false, `print the text files in DIR arguments and their subdirectories with a header, skipping what .gitignore and .ignore files ignore`)
+	flags.StringArrayVarP(&c.Include, "include", "", nil, `with -r, only print files matching the glob; globs with a / match the path within DIR, ** matches any directories and the flag can be repeated`)
+	flags.StringArrayVarP(&c.Exclude, "exclude", "", nil, `with -r, skip files and directories matching the glob; the flag can be repeated`)
+	flags.IntVarP(&c.MaxDepth, "max-depth", "", 0, `with -r, don't descend more than N directories below DIR arguments; 0 for no limit`)
+	flags.BoolVarP(&c.Follow, "follow", "f", false, `keep printing what's appended to FILE, following it by name when it's truncated or replaced, like tail -F`)
+	flags.IntVarP(&c.Tail, "tail", "", -1, `start printing from the last N lines of files; -1 prints them whole`)
+	flags.IntVarP(&c.Jobs, "jobs", "j", 0, `number of files highlighted at once, which are still printed in order; 0 for the number of CPUs`)
+	flags.BoolVarP(&c.ShowAll, "show-all", "A", false, `equivalent to -vET`)
+	flags.BoolVarP(&c.Cat.NumberNonblank, "number-nonblank", "b", false, `number nonempty output lines, overrides -n`)
+	flags.BoolVarP(&c.Cat.ShowEnds, "show-ends", "E", false, `display $ at end of each line`)
+	flags.BoolVarP(&c.Cat.Number, "number", "n", false, `number all output lines`)
+	flags.BoolVarP(&c.Cat.SqueezeBlank, "squeeze-blank", "s", false, `suppress repeated empty output lines`)
+	flags.BoolVarP(&c.Cat.ShowTabs, "show-tabs", "T", false, `display TAB characters as ^I`)
+	flags.BoolVarP(&c.Cat.ShowNonprinting, "show-nonprinting", "v", false, `use ^ and M- notation, except for LFD, TAB and printable UTF-8`)
+	flags.BoolVarP(&c.ShowVersion, "version", "", false, `show version`)
 }
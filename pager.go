@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultPager = "less -RFX"
+
+// pagerStall is how long Pager holds output that doesn't fill the
+// terminal yet when Pager.Stall isn't set.
+const pagerStall = 250 * time.Millisecond
+
+// errPagerQuit is returned by Pager.Write once the pager has exited,
+// e.g. because the user quit less before reaching the end.
+var errPagerQuit = errors.New("pager quit")
+
+// pagerCommand returns the pager set by $CCAT_PAGER or $PAGER. An empty
+// $CCAT_PAGER turns paging off.
+func pagerCommand() []string {
+	s, ok := os.LookupEnv("CCAT_PAGER")
+	if !ok {
+		s = os.Getenv("PAGER")
+		if s == "" {
+			s = defaultPager
+		}
+	}
+
+	args := strings.Fields(s)
+	// plain less would print the color codes instead of interpreting them
+	if len(args) == 1 && filepath.Base(args[0]) == "less" {
+		args = strings.Fields(defaultPager)
+	}
+
+	return args
+}
+
+// terminalHeight returns the height of the terminal on standard output,
+// or of $LINES if it's not a terminal. It returns 0 if neither is known.
+func terminalHeight() int {
+	if _, h, ok := terminalSize(uintptr(syscall.Stdout)); ok {
+		return h
+	}
+	if h, err := strconv.Atoi(os.Getenv("LINES")); err == nil && h > 0 {
+		return h
+	}
+
+	return 0
+}
+
+// Pager writes to Out until the output has more lines than fit in Height,
+// then starts Command and writes everything to it instead. With a Height
+// of 0 the pager is started right away. If Command can't be started, the
+// output goes to Out. Output that stalls for Stall before filling the
+// terminal, like that of tail -f app.log | ccat, isn't paged but written
+// to Out as it comes.
+type Pager struct {
+	Command []string
+	Height  int
+	Out     io.Writer
+	Stall   time.Duration
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	lines  int
+	direct bool
+	timer  *time.Timer
+	err    error
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	quit   bool
+}
+
+func (p *Pager) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.quit {
+		return 0, errPagerQuit
+	}
+	if p.direct {
+		return p.Out.Write(b)
+	}
+
+	if p.cmd != nil {
+		n, err := p.stdin.Write(b)
+		if err != nil {
+			p.quit = true
+			return n, errPagerQuit
+		}
+		return n, nil
+	}
+
+	if p.Height > 0 {
+		p.lines += bytes.Count(b, []byte{'\n'})
+		// leave a line for the prompt of the shell
+		if p.lines < p.Height {
+			p.wait()
+			return p.buf.Write(b)
+		}
+	}
+	p.stopWaiting()
+
+	p.buf.Write(b)
+	if err := p.start(); err != nil {
+		p.direct = true
+		return len(b), p.flush()
+	}
+
+	_, err := p.stdin.Write(p.buf.Bytes())
+	p.buf.Reset()
+	if err != nil {
+		p.quit = true
+		return len(b), errPagerQuit
+	}
+
+	return len(b), nil
+}
+
+// wait has what's buffered written to Out if nothing else is written for
+// Stall.
+func (p *Pager) wait() {
+	stall := p.Stall
+	if stall <= 0 {
+		stall = pagerStall
+	}
+
+	if p.timer == nil {
+		p.timer = time.AfterFunc(stall, p.stalled)
+	} else {
+		p.timer.Reset(stall)
+	}
+}
+
+func (p *Pager) stopWaiting() {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
+
+func (p *Pager) stalled() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil && !p.direct {
+		p.direct = true
+		p.err = p.flush()
+	}
+}
+
+func (p *Pager) start() error {
+	if len(p.Command) == 0 {
+		return errors.New("no pager")
+	}
+
+	cmd := exec.Command(p.Command[0], p.Command[1:]...)
+	cmd.Stdout = p.Out
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// the pager handles ^C itself, ccat just stops once it quits
+	signal.Ignore(os.Interrupt)
+
+	p.cmd = cmd
+	p.stdin = stdin
+
+	return nil
+}
+
+func (p *Pager) flush() error {
+	_, err := p.buf.WriteTo(p.Out)
+
+	return err
+}
+
+// Close writes what's left to Out if the pager wasn't needed, or waits for
+// the user to quit the pager.
+func (p *Pager) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopWaiting()
+	if p.cmd == nil {
+		if p.err != nil {
+			return p.err
+		}
+		return p.flush()
+	}
+
+	p.stdin.Close()
+	err := p.cmd.Wait()
+	signal.Reset(os.Interrupt)
+
+	// the exit status of pagers isn't meaningful, e.g. less exits with 1
+	// after ^C
+	if _, ok := err.(*exec.ExitError); ok {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPagerFits(t *testing.T) {
+	var w bytes.Buffer
+	p := &Pager{Command: []string{"false"}, Height: 3, Out: &w}
+	p.Write([]byte("a\nb\n"))
+	if w.Len() > 0 {
+		t.Errorf("output should be buffered: %q", w.String())
+	}
+
+	err := p.Close()
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+	if w.String() != "a\nb\n" || p.cmd != nil {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestPagerPages(t *testing.T) {
+	var w bytes.Buffer
+	p := &Pager{Command: []string{"sed", "s/^/> /"}, Height: 3, Out: &w}
+	for _, s := range []string{"a\nb\n", "c\nd\n", "e\n"} {
+		_, err := p.Write([]byte(s))
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+	}
+
+	err := p.Close()
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+	if w.String() != "> a\n> b\n> c\n> d\n> e\n" {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestPagerStall(t *testing.T) {
+	out := make(lineWriter, 100)
+	p := &Pager{Command: []string{"sed", "s/^/> /"}, Height: 3, Out: out, Stall: 10 * time.Millisecond}
+
+	// output that stops coming before it fills the terminal is written
+	// out as is, and so is what comes after it
+	for _, s := range []string{"a\n", "b\nc\nd\n"} {
+		if _, err := p.Write([]byte(s)); err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+		waitFor(t, out, s)
+	}
+
+	err := p.Close()
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+	if p.cmd != nil {
+		t.Errorf("pager shouldn't be started")
+	}
+}
+
+func TestPagerQuit(t *testing.T) {
+	var w bytes.Buffer
+	p := &Pager{Command: []string{"true"}, Out: &w}
+
+	chunk := []byte(strings.Repeat("x\n", 1<<16))
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		_, err = p.Write(chunk)
+	}
+	if err != errPagerQuit {
+		t.Errorf("error should be errPagerQuit, but it's %v", err)
+	}
+
+	err = p.Close()
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+}
+
+func TestPagerMissing(t *testing.T) {
+	var w bytes.Buffer
+	p := &Pager{Command: []string{"ccat-no-such-pager"}, Out: &w}
+	p.Write([]byte("a\n"))
+	p.Write([]byte("b\n"))
+
+	err := p.Close()
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+	if w.String() != "a\nb\n" {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestPagerCommand(t *testing.T) {
+	ccatPager, hasCcatPager := os.LookupEnv("CCAT_PAGER")
+	pager := os.Getenv("PAGER")
+	defer func() {
+		if hasCcatPager {
+			os.Setenv("CCAT_PAGER", ccatPager)
+		} else {
+			os.Unsetenv("CCAT_PAGER")
+		}
+		os.Setenv("PAGER", pager)
+	}()
+
+	cases := []struct {
+		CcatPager *string
+		Pager     string
+		Expected  []string
+	}{
+		{nil, "", []string{"less", "-RFX"}},
+		{nil, "less", []string{"less", "-RFX"}},
+		{nil, "more -d", []string{"more", "-d"}},
+		{strPtr("most"), "more", []string{"most"}},
+		{strPtr(""), "more", []string{}},
+	}
+
+	for _, tc := range cases {
+		os.Unsetenv("CCAT_PAGER")
+		if tc.CcatPager != nil {
+			os.Setenv("CCAT_PAGER", *tc.CcatPager)
+		}
+		os.Setenv("PAGER", tc.Pager)
+
+		args := pagerCommand()
+		if !reflect.DeepEqual(args, tc.Expected) {
+			t.Errorf("PAGER: %s\n\nOutput: %q\n\nExpected: %q", tc.Pager, args, tc.Expected)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
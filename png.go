@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+const (
+	pngCellWidth  = glyphWidth + 1
+	pngLineHeight = glyphHeight + 3
+)
+
+func PngPrint(src TokenSource, w io.Writer, p PngPrinter) error {
+	lines, err := readLines(src)
+	if err != nil {
+		return err
+	}
+
+	background, foreground := themeColors(p.BG)
+	if p.Background != "" {
+		background, err = parseColor(p.Background)
+		if err != nil {
+			return err
+		}
+	}
+
+	scale := p.Scale
+	if scale < 1 {
+		scale = 1
+	}
+
+	gutter := 0
+	if p.LineNumbers {
+		gutter = len(strconv.Itoa(len(lines))) + 1
+	}
+
+	cols := 0
+	spans := make([][]cellSpan, len(lines))
+	for i, line := range lines {
+		var n int
+		spans[i], n = layoutLine(line, p.ColorPalettes)
+		if n > cols {
+			cols = n
+		}
+	}
+
+	width := 2*p.Padding + (gutter+cols)*pngCellWidth
+	height := 2*p.Padding + len(lines)*pngLineHeight
+	img := image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.ZP, draw.Src)
+
+	c := canvas{img, scale}
+	for i, line := range spans {
+		y := p.Padding + i*pngLineHeight + 1
+
+		if p.LineNumbers {
+			num := fmt.Sprintf("%*d", gutter-1, i+1)
+			for j, r := range num {
+				c.drawGlyph(p.Padding+j*pngCellWidth, y, glyph(r), rgbCodes["darkgray"], textStyle{})
+			}
+		}
+
+		for _, s := range line {
+			fg := s.Style.RGB(foreground)
+			col := gutter + s.Col
+			for _, r := range s.Text {
+				rw := runeWidth(r)
+				if rw == 0 {
+					continue
+				}
+
+				x := p.Padding + col*pngCellWidth
+				if rw == 2 {
+					c.drawMissingWide(x, y, fg)
+				} else {
+					c.drawGlyph(x, y, glyph(r), fg, s.Style)
+				}
+				col += rw
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// canvas draws on an image in unscaled coordinates.
+type canvas struct {
+	img   *image.RGBA
+	scale int
+}
+
+func (c canvas) set(x, y int, col color.RGBA) {
+	r := image.Rect(x*c.scale, y*c.scale, (x+1)*c.scale, (y+1)*c.scale)
+	draw.Draw(c.img, r, &image.Uniform{col}, image.ZP, draw.Src)
+}
+
+func (c canvas) drawGlyph(x, y int, g [glyphHeight]uint8, col color.RGBA, s textStyle) {
+	for row, bits := range g {
+		for i := 0; i < glyphWidth; i++ {
+			if bits&(1<<uint(glyphWidth-1-i)) == 0 {
+				continue
+			}
+
+			c.set(x+i, y+row, col)
+			if s.Bold {
+				c.set(x+i+1, y+row, col)
+			}
+		}
+	}
+
+	if s.Underline {
+		for i := 0; i < pngCellWidth; i++ {
+			c.set(x+i, y+glyphHeight-1, col)
+		}
+	}
+}
+
+// drawMissingWide draws a box in place of a wide character,
+// which the font doesn't cover.
+func (c canvas) drawMissingWide(x, y int, col color.RGBA) {
+	w := 2*pngCellWidth - 1
+	h := glyphHeight - 2
+	for i := 0; i < w; i++ {
+		c.set(x+i, y, col)
+		c.set(x+i, y+h-1, col)
+	}
+	for j := 0; j < h; j++ {
+		c.set(x, y+j, col)
+		c.set(x+w-1, y+j, col)
+	}
+}
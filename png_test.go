@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestPngPrint(t *testing.T) {
+	r := bytes.NewBufferString("hi\n日\n")
+	var w bytes.Buffer
+
+	p := PngPrinter{
+		ColorPalettes: LightColorPalettes,
+		Background:    "#102030",
+		LineNumbers:   true,
+		Padding:       4,
+		Scale:         2,
+	}
+	err := PngPrint(Lex(r), &w, p)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	img, err := png.Decode(&w)
+	if err != nil {
+		t.Fatalf("output should be a png: %s", err)
+	}
+
+	// 2 gutter cells and 2 cells for the widest line
+	size := img.Bounds().Size()
+	if size.X != (2*4+4*pngCellWidth)*2 || size.Y != (2*4+2*pngLineHeight)*2 {
+		t.Errorf("image size is wrong: %v", size)
+	}
+
+	r0, g0, b0, _ := img.At(0, 0).RGBA()
+	if r0>>8 != 0x10 || g0>>8 != 0x20 || b0>>8 != 0x30 {
+		t.Errorf("background is wrong: %v", img.At(0, 0))
+	}
+
+	// the stem of the "h" in the first column of the code
+	x, y := (4+2*pngCellWidth)*2, (4+1)*2
+	r1, g1, b1, _ := img.At(x, y).RGBA()
+	blue := rgbCodes["darkblue"]
+	if uint8(r1>>8) != blue.R || uint8(g1>>8) != blue.G || uint8(b1>>8) != blue.B {
+		t.Errorf("glyph color is wrong: %v", img.At(x, y))
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := parseColor("#0a0b0c")
+	if err != nil || c.R != 0x0a || c.G != 0x0b || c.B != 0x0c {
+		t.Errorf("parsing #0a0b0c is wrong: %v, %s", c, err)
+	}
+
+	c, err = parseColor("teal")
+	if err != nil || c != rgbCodes["teal"] {
+		t.Errorf("parsing teal is wrong: %v, %s", c, err)
+	}
+
+	_, err = parseColor("#zzzzzz")
+	if err == nil {
+		t.Errorf("parsing #zzzzzz should fail")
+	}
+}
+
+func TestPaletteColors(t *testing.T) {
+	for _, palettes := range []ColorPalettes{LightColorPalettes, DarkColorPalettes} {
+		for kind, code := range palettes {
+			style := parseStyle(code)
+			if style.Color == "" {
+				continue
+			}
+			if _, err := parseColor(style.Color); err != nil {
+				t.Errorf("color of %v has no RGB value: %s", kind, code)
+			}
+		}
+	}
+}
@@ -1,14 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/sourcegraph/syntaxhighlight"
 )
 
+// Kinds of the text ccat adds to the highlighted tokens. They're numbered
+// after the kinds of syntaxhighlight.
+const (
+	LineNumber syntaxhighlight.Kind = syntaxhighlight.Decimal + 1 + iota
+	Marker
+	GitAdded
+	GitModified
+	GitRemoved
+	TargetLine
+	Header
+	Invisible
+	Warning
+	HexOffset
+	HexNull
+	HexPrintable
+	HexWhitespace
+	HexControl
+	HexHighBit
+	TreeDir
+	TreeLink
+)
+
 var (
 	stringKind        = kind{"String", syntaxhighlight.String}
 	keywordKind       = kind{"Keyword", syntaxhighlight.Keyword}
@@ -22,6 +46,23 @@ var (
 	htmlAttrNameKind  = kind{"HTMLAttrName", syntaxhighlight.HTMLAttrName}
 	htmlAttrValueKind = kind{"HTMLAttrValue", syntaxhighlight.HTMLAttrValue}
 	decimalKind       = kind{"Decimal", syntaxhighlight.Decimal}
+	lineNumberKind    = kind{"LineNumber", LineNumber}
+	markerKind        = kind{"Marker", Marker}
+	gitAddedKind      = kind{"GitAdded", GitAdded}
+	gitModifiedKind   = kind{"GitModified", GitModified}
+	gitRemovedKind    = kind{"GitRemoved", GitRemoved}
+	targetLineKind    = kind{"TargetLine", TargetLine}
+	headerKind        = kind{"Header", Header}
+	invisibleKind     = kind{"Invisible", Invisible}
+	warningKind       = kind{"Warning", Warning}
+	hexOffsetKind     = kind{"HexOffset", HexOffset}
+	hexNullKind       = kind{"HexNull", HexNull}
+	hexPrintableKind  = kind{"HexPrintable", HexPrintable}
+	hexWhitespaceKind = kind{"HexWhitespace", HexWhitespace}
+	hexControlKind    = kind{"HexControl", HexControl}
+	hexHighBitKind    = kind{"HexHighBit", HexHighBit}
+	treeDirKind       = kind{"TreeDir", TreeDir}
+	treeLinkKind      = kind{"TreeLink", TreeLink}
 
 	kinds = []kind{
 		stringKind,
@@ -36,6 +77,23 @@ var (
 		htmlAttrNameKind,
 		htmlAttrValueKind,
 		decimalKind,
+		lineNumberKind,
+		markerKind,
+		gitAddedKind,
+		gitModifiedKind,
+		gitRemovedKind,
+		targetLineKind,
+		headerKind,
+		invisibleKind,
+		warningKind,
+		hexOffsetKind,
+		hexNullKind,
+		hexPrintableKind,
+		hexWhitespaceKind,
+		hexControlKind,
+		hexHighBitKind,
+		treeDirKind,
+		treeLinkKind,
 	}
 
 	LightColorPalettes = ColorPalettes{
@@ -51,6 +109,23 @@ var (
 		htmlAttrNameKind:  "blue",
 		htmlAttrValueKind: "green",
 		decimalKind:       "darkblue",
+		lineNumberKind:    "darkgray",
+		markerKind:        "purple",
+		gitAddedKind:      "darkgreen",
+		gitModifiedKind:   "brown",
+		gitRemovedKind:    "darkred",
+		targetLineKind:    "*purple*",
+		headerKind:        "*black*",
+		invisibleKind:     "lightgray",
+		warningKind:       "*darkred*",
+		hexOffsetKind:     "darkgray",
+		hexNullKind:       "lightgray",
+		hexPrintableKind:  "teal",
+		hexWhitespaceKind: "darkgreen",
+		hexControlKind:    "purple",
+		hexHighBitKind:    "brown",
+		treeDirKind:       "*darkblue*",
+		treeLinkKind:      "teal",
 	}
 
 	DarkColorPalettes = ColorPalettes{
@@ -66,6 +141,23 @@ var (
 		htmlAttrNameKind:  "blue",
 		htmlAttrValueKind: "green",
 		decimalKind:       "blue",
+		lineNumberKind:    "darkgray",
+		markerKind:        "fuchsia",
+		gitAddedKind:      "green",
+		gitModifiedKind:   "yellow",
+		gitRemovedKind:    "red",
+		targetLineKind:    "*fuchsia*",
+		headerKind:        "*white*",
+		invisibleKind:     "darkgray",
+		warningKind:       "*red*",
+		hexOffsetKind:     "darkgray",
+		hexNullKind:       "darkgray",
+		hexPrintableKind:  "turquoise",
+		hexWhitespaceKind: "green",
+		hexControlKind:    "fuchsia",
+		hexHighBitKind:    "yellow",
+		treeDirKind:       "*blue*",
+		treeLinkKind:      "turquoise",
 	}
 
 	// cache kind name and syntax highlight kind
@@ -103,8 +195,9 @@ func (c ColorPalettes) Set(k, v string) bool {
 }
 
 func (c ColorPalettes) Get(k syntaxhighlight.Kind) string {
-	// ignore whitespace kind
-	if k == syntaxhighlight.Whitespace {
+	// ignore whitespace kind, and the styles of the input, which
+	// aren't in the palettes
+	if k == syntaxhighlight.Whitespace || k >= ansiKindBase {
 		return ""
 	}
 
@@ -126,8 +219,8 @@ func (c ColorPalettes) String() string {
 	return strings.Join(s, "\n")
 }
 
-func CPrint(r io.Reader, w io.Writer, palettes ColorPalettes) error {
-	return syntaxhighlight.Print(syntaxhighlight.NewScannerReader(r), w, Printer{palettes})
+func CPrint(src TokenSource, w io.Writer, palettes ColorPalettes) error {
+	return src(w, Printer{palettes})
 }
 
 type Printer struct {
@@ -135,8 +228,11 @@ type Printer struct {
 }
 
 func (p Printer) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
-	c := p.ColorPalettes.Get(kind)
-	if len(c) > 0 {
+	if s, ok := kindANSIStyle(kind); ok {
+		if s != (ansiStyle{}) {
+			tokText = s.SGR() + tokText + colorCodes["reset"]
+		}
+	} else if c := p.ColorPalettes.Get(kind); len(c) > 0 {
 		tokText = Colorize(c, tokText)
 	}
 
@@ -145,7 +241,7 @@ func (p Printer) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) e
 	return err
 }
 
-func HtmlPrint(r io.Reader, w io.Writer, palettes ColorPalettes) error {
+func HtmlPrint(src TokenSource, w io.Writer, palettes ColorPalettes) error {
 	keys := []string{}
 	for k := range htmlCodes {
 		keys = append(keys, k)
@@ -160,18 +256,31 @@ func HtmlPrint(r io.Reader, w io.Writer, palettes ColorPalettes) error {
 	}
 	w.Write([]byte("</style>\n"))
 	w.Write([]byte("<pre>\n"))
-	err := syntaxhighlight.Print(syntaxhighlight.NewScannerReader(r), w, HtmlCodePrinter{palettes})
+	err := src(w, HtmlCodePrinter{palettes})
 	w.Write([]byte("\n</pre>\n"))
 	return err
 }
 
+type PlainTextCodePrinter struct {
+}
+
+func (p PlainTextCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	_, err := io.WriteString(w, tokText)
+
+	return err
+}
+
 type HtmlCodePrinter struct {
 	ColorPalettes ColorPalettes
 }
 
 func (p HtmlCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
-	c := p.ColorPalettes.Get(kind)
-	if len(c) > 0 {
+	tokText = template.HTMLEscapeString(tokText)
+	if s, ok := kindANSIStyle(kind); ok {
+		if css := s.CSS(); css != "" {
+			tokText = fmt.Sprintf(`<span style="%s">%s</span>`, css, tokText)
+		}
+	} else if c := p.ColorPalettes.Get(kind); len(c) > 0 {
 		tokText = Htmlize(c, tokText)
 	}
 
@@ -179,3 +288,87 @@ func (p HtmlCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText s
 
 	return err
 }
+
+// lineToken is a highlighted piece of text that doesn't span lines.
+type lineToken struct {
+	Kind syntaxhighlight.Kind
+	Text string
+}
+
+// readLines splits the tokens of src into lines for printers that lay
+// out the whole document before writing it.
+func readLines(src TokenSource) ([][]lineToken, error) {
+	var b lineBuffer
+	err := src(nil, &b)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b.line) > 0 {
+		b.lines = append(b.lines, b.line)
+	}
+
+	return b.lines, nil
+}
+
+type lineBuffer struct {
+	lines [][]lineToken
+	line  []lineToken
+}
+
+func (b *lineBuffer) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	for {
+		i := strings.IndexByte(tokText, '\n')
+		if i < 0 {
+			break
+		}
+
+		if i > 0 {
+			b.line = append(b.line, lineToken{kind, tokText[:i]})
+		}
+		b.lines = append(b.lines, b.line)
+		b.line = nil
+		tokText = tokText[i+1:]
+	}
+
+	if len(tokText) > 0 {
+		b.line = append(b.line, lineToken{kind, tokText})
+	}
+
+	return nil
+}
+
+// tokenRun joins consecutive text of the same kind into a single token
+// for printers that process their input a character at a time.
+type tokenRun struct {
+	kind syntaxhighlight.Kind
+	text bytes.Buffer
+}
+
+func (t *tokenRun) Add(w io.Writer, p syntaxhighlight.Printer, kind syntaxhighlight.Kind, text string) error {
+	if t.text.Len() > 0 && kind != t.kind {
+		if err := t.Flush(w, p); err != nil {
+			return err
+		}
+	}
+	t.kind = kind
+	t.text.WriteString(text)
+
+	// lines are printed as soon as they're over
+	if strings.HasSuffix(text, "\n") {
+		return t.Flush(w, p)
+	}
+
+	return nil
+}
+
+func (t *tokenRun) Flush(w io.Writer, p syntaxhighlight.Printer) error {
+	if t.text.Len() == 0 {
+		return nil
+	}
+
+	text := t.text.String()
+	t.text.Reset()
+
+	return p.Print(w, t.kind, text)
+}
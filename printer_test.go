@@ -9,7 +9,7 @@ func TestCPrint(t *testing.T) {
 	r := bytes.NewBufferString("hello")
 	var w bytes.Buffer
 
-	err := CPrint(r, &w, LightColorPalettes)
+	err := CPrint(Lex(r), &w, LightColorPalettes)
 	if err != nil {
 		t.Errorf("error should be nil, but it's %s", err)
 	}
@@ -24,7 +24,7 @@ func TestHtmlPrint(t *testing.T) {
 	r := bytes.NewBufferString("hello")
 	var w bytes.Buffer
 
-	err := HtmlPrint(r, &w, LightColorPalettes)
+	err := HtmlPrint(Lex(r), &w, LightColorPalettes)
 	if err != nil {
 		t.Errorf("error should be nil, but it's %s", err)
 	}
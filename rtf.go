@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+const rtfFontSize = 20 // in half-points
+
+func RtfPrint(src TokenSource, w io.Writer, palettes ColorPalettes) error {
+	colors, table := rtfColorTable(palettes)
+
+	_, err := fmt.Fprintf(w, "{\\rtf1\\ansi\\ansicpg1252\\deff0\\uc1\n{\\fonttbl{\\f0\\fmodern\\fcharset0 Menlo;}}\n{\\colortbl ;%s}\n\\f0\\fs%d\n", table, rtfFontSize)
+	if err != nil {
+		return err
+	}
+
+	err = src(w, RtfCodePrinter{palettes, colors})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "}\n")
+
+	return err
+}
+
+// rtfColorTable returns the color table of the document and the index
+// of each color in it. Index 0 is the default color of the document.
+func rtfColorTable(palettes ColorPalettes) (map[string]int, string) {
+	colors := make(map[string]int)
+	var table bytes.Buffer
+	for _, k := range kinds {
+		s := parseStyle(palettes[k])
+		c, ok := rgbCodes[s.Color]
+		if !ok || colors[s.Color] > 0 {
+			continue
+		}
+
+		colors[s.Color] = len(colors) + 1
+		fmt.Fprintf(&table, "\\red%d\\green%d\\blue%d;", c.R, c.G, c.B)
+	}
+
+	return colors, table.String()
+}
+
+type RtfCodePrinter struct {
+	ColorPalettes ColorPalettes
+	Colors        map[string]int
+}
+
+func (p RtfCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	s := parseStyle(p.ColorPalettes.Get(kind))
+
+	var ctrl bytes.Buffer
+	if i := p.Colors[s.Color]; i > 0 {
+		fmt.Fprintf(&ctrl, "\\cf%d", i)
+	}
+	if s.Bold {
+		ctrl.WriteString("\\b")
+	}
+	if s.Underline {
+		ctrl.WriteString("\\ul")
+	}
+
+	text := rtfEscape(tokText)
+	if ctrl.Len() > 0 {
+		text = fmt.Sprintf("{%s %s}", ctrl.String(), text)
+	}
+
+	_, err := io.WriteString(w, text)
+
+	return err
+}
+
+// rtfEscape escapes RTF control characters in s and encodes non-ASCII
+// characters as \u control words with a "?" fallback.
+func rtfEscape(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '{' || r == '}':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString("\\par\n")
+		case r == '\t':
+			b.WriteString("\\tab ")
+		case r < 0x20 || r == 0x7f:
+			// drop other control characters
+		case r < 0x80:
+			b.WriteRune(r)
+		default:
+			// \u takes a signed 16-bit value, so characters outside
+			// the BMP are written as surrogate pairs
+			units := []rune{r}
+			if r1, r2 := utf16.EncodeRune(r); r1 != 0xfffd || r2 != 0xfffd {
+				units = []rune{r1, r2}
+			}
+			for _, u := range units {
+				fmt.Fprintf(&b, "\\u%d?", int16(u))
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRtfPrint(t *testing.T) {
+	r := bytes.NewBufferString("a {b}\n")
+	var w bytes.Buffer
+
+	palettes := ColorPalettes{
+		plaintextKind:   "darkblue",
+		punctuationKind: "*darkred*",
+	}
+	err := RtfPrint(Lex(r), &w, palettes)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expect := `{\rtf1\ansi\ansicpg1252\deff0\uc1
+{\fonttbl{\f0\fmodern\fcharset0 Menlo;}}
+{\colortbl ;\red204\green0\blue0;\red52\green101\blue164;}
+\f0\fs20
+{\cf2 a} {\cf1\b \{}{\cf2 b}{\cf1\b \}}\par
+}
+`
+
+	s := w.String()
+	if s != expect {
+		t.Errorf("output is wrong: %s", s)
+	}
+}
+
+func TestRtfEscape(t *testing.T) {
+	cases := []struct {
+		Text, Output string
+	}{
+		{`C:\dir`, `C:\\dir`},
+		{"\tx", `\tab x`},
+		{"é", `\u233?`},
+		{"日", `\u26085?`},
+		{"😀", `\u-10179?\u-8704?`},
+	}
+
+	for _, tc := range cases {
+		actual := rtfEscape(tc.Text)
+		if actual != tc.Output {
+			t.Errorf("Text: %q\n\nOutput: %q\n\nExpected: %q", tc.Text, actual, tc.Output)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// lexWindow is the most text that's lexed at once. Input is lexed in
+// windows that end at line breaks, so that each line is printed as soon
+// as it's read and memory doesn't grow with the size of the input.
+const lexWindow = 64 << 10
+
+// lexStall is how long the input may stall before the whole lines read so
+// far are printed, even if a token spanning lines isn't over yet, as with
+// a log line with an unclosed quote.
+const lexStall = 250 * time.Millisecond
+
+type lexToken struct {
+	kind syntaxhighlight.Kind
+	text string
+}
+
+// lexStream prints the tokens of r to p a window at a time. The text after
+// the last line break of a window is lexed again with the next one, so a
+// token spanning lines, like a block comment, is only cut when it doesn't
+// fit in a window or the input stalls.
+func lexStream(r io.Reader, w io.Writer, p syntaxhighlight.Printer) error {
+	// r is read in a goroutine of its own so that a stall can be told
+	// apart from a read
+	next := make(chan []byte)
+	reads := make(chan lexRead, 1)
+	defer close(next)
+	go func() {
+		for b := range next {
+			n, err := r.Read(b)
+			reads <- lexRead{n, err}
+		}
+	}()
+
+	buf := make([]byte, 0, 2*lexWindow)
+	eof := false
+	for !eof || len(buf) > 0 {
+		if !eof {
+			next <- buf[len(buf):cap(buf)]
+
+			var read lexRead
+			flushed := 0
+			stall := time.NewTimer(lexStall)
+			select {
+			case read = <-reads:
+			case <-stall.C:
+				// the lines held so far are before what's being read
+				var err error
+				if flushed, err = printLines(buf, w, p); err != nil {
+					return err
+				}
+				read = <-reads
+			}
+			stall.Stop()
+
+			n, err := read.n, read.err
+			buf = buf[:len(buf)+n]
+			buf = buf[:copy(buf, buf[flushed:])]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+
+			// wait for a whole line unless the window is full
+			if !eof && len(buf) < lexWindow && bytes.IndexByte(buf[len(buf)-n:], '\n') < 0 {
+				continue
+			}
+		}
+
+		full := !eof && len(buf) >= lexWindow
+		text := buf
+		if full {
+			text = buf[:completeRunes(buf)]
+		}
+		toks := lexTokens(text)
+
+		n := len(toks)
+		if !eof {
+			n = lastLineBreak(toks)
+			switch {
+			case n > 0:
+			case !full:
+				// a token spanning lines isn't over yet
+				continue
+			case len(toks) > 1:
+				// the last token of a full window may go on in the next
+				n = len(toks) - 1
+			default:
+				n = 1
+			}
+		}
+
+		consumed := 0
+		for _, tok := range toks[:n] {
+			if err := printReplaced(w, p, tok.kind, tok.text); err != nil {
+				return err
+			}
+			consumed += len(tok.text)
+		}
+		buf = buf[:copy(buf, buf[consumed:])]
+	}
+
+	return nil
+}
+
+type lexRead struct {
+	n   int
+	err error
+}
+
+// printLines prints the tokens of the whole lines at the start of buf,
+// cutting a token that goes on after them, and returns their length.
+func printLines(buf []byte, w io.Writer, p syntaxhighlight.Printer) (int, error) {
+	n := bytes.LastIndexByte(buf, '\n') + 1
+	if n == 0 {
+		return 0, nil
+	}
+
+	// the token that's cut ends the lines with a line break of its own
+	toks := lexTokens(buf[:n-1])
+	toks = append(toks, lexToken{syntaxhighlight.Whitespace, "\n"})
+	for _, tok := range toks {
+		if err := printReplaced(w, p, tok.kind, tok.text); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+func lexTokens(text []byte) []lexToken {
+	var toks []lexToken
+	s := syntaxhighlight.NewScanner(text)
+	syntaxhighlight.Print(s, nil, tokenCollector(func(kind syntaxhighlight.Kind, text string) {
+		toks = append(toks, lexToken{kind, text})
+	}))
+
+	return toks
+}
+
+type tokenCollector func(kind syntaxhighlight.Kind, text string)
+
+func (c tokenCollector) Print(w io.Writer, kind syntaxhighlight.Kind, text string) error {
+	c(kind, text)
+
+	return nil
+}
+
+// lastLineBreak returns the number of tokens up to the last line break.
+func lastLineBreak(toks []lexToken) int {
+	for i := len(toks) - 1; i >= 0; i-- {
+		if toks[i].text == "\n" {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// completeRunes returns the length of b without a character that's cut
+// off at its end.
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+
+	return len(b)
+}
+
+// peekAvailable returns the next n bytes of br, or fewer if that's all a
+// read returns, so that looking at the start of interactive input doesn't
+// wait for more of it than was typed.
+func peekAvailable(br *bufio.Reader, n int) ([]byte, error) {
+	if _, err := br.Peek(1); err != nil {
+		return nil, err
+	}
+	if b := br.Buffered(); b < n {
+		n = b
+	}
+
+	return br.Peek(n)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+func TestLexStream(t *testing.T) {
+	var long bytes.Buffer
+	for i := 0; long.Len() < 3*lexWindow; i++ {
+		fmt.Fprintf(&long, "x := %d // line %d ünïcödé\n/* a comment\nover lines */ s := `raw\nstring`\n", i, i)
+	}
+
+	cases := []string{
+		"",
+		"package main\n",
+		"func f() {\n\treturn \"<b>\"\n}",
+		"/* a comment\nover\nlines */\nx := 1\n",
+		"s := `a raw\nstring` + \"and\"\n\n\n",
+		long.String(),
+	}
+
+	for _, input := range cases {
+		var expected bytes.Buffer
+		syntaxhighlight.Print(syntaxhighlight.NewScanner([]byte(input)), &expected, PlainTextCodePrinter{})
+		expectedTokens := lexTokens([]byte(input))
+
+		for _, r := range []io.Reader{strings.NewReader(input), iotest.OneByteReader(strings.NewReader(input))} {
+			var w bytes.Buffer
+			var toks []lexToken
+			err := lexStream(r, &w, tokenCollector(func(kind syntaxhighlight.Kind, text string) {
+				toks = append(toks, lexToken{kind, text})
+				w.WriteString(text)
+			}))
+			if err != nil {
+				t.Errorf("error should be nil, but it's %s", err)
+			}
+			if w.String() != expected.String() {
+				t.Errorf("output is wrong: %q", w.String())
+			}
+			if len(toks) != len(expectedTokens) {
+				t.Errorf("Input: %.40q\n\ntokens are wrong: %d, expected %d", input, len(toks), len(expectedTokens))
+			}
+		}
+	}
+}
+
+func TestLexStreamLongLine(t *testing.T) {
+	// a line longer than a window is cut, but keeps its characters whole
+	input := strings.Repeat("é", lexWindow) + "\n"
+
+	var w bytes.Buffer
+	err := lexStream(strings.NewReader(input), &w, tokenCollector(func(kind syntaxhighlight.Kind, text string) {
+		if strings.ContainsRune(text, '�') {
+			t.Errorf("character is cut: %q", text[:8])
+		}
+		w.WriteString(text)
+	}))
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+	if w.String() != input {
+		t.Errorf("output is wrong: %d bytes", w.Len())
+	}
+}
+
+func TestLexStreamStall(t *testing.T) {
+	in, pw := io.Pipe()
+	out := make(lineWriter, 100)
+	done := make(chan error)
+	go func() {
+		done <- lexStream(in, out, PlainTextCodePrinter{})
+	}()
+
+	// lines with an unclosed quote or comment are printed once the input
+	// stalls, like log lines read as they're written
+	for _, line := range []string{"GET \"/api 200\n", "GET /api/* 200\n", "more\n"} {
+		pw.Write([]byte(line))
+		waitFor(t, out, line)
+	}
+
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+}
+
+func TestCompleteRunes(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"aé", 3},
+		{"a\xc3", 1},
+		{"a\xe4\xb8", 1},
+		{"a世", 4},
+		{"a\xf0\x9f\x98", 1},
+		{"\xff\xff", 2},
+	}
+
+	for _, tc := range cases {
+		if n := completeRunes([]byte(tc.Input)); n != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %d\n\nExpected: %d", tc.Input, n, tc.Expected)
+		}
+	}
+}
+
+// withStdin makes os.Stdin read what's written to the returned pipe until
+// restore is called.
+func withStdin(t *testing.T) (*os.File, func()) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = r
+
+	return w, func() {
+		os.Stdin = stdin
+		r.Close()
+	}
+}
+
+// lineWriter sends each write to a channel.
+type lineWriter chan string
+
+func (c lineWriter) Write(p []byte) (int, error) {
+	c <- string(p)
+	return len(p), nil
+}
+
+func TestCCatStdinInteractive(t *testing.T) {
+	out := make(lineWriter, 100)
+	done := make(chan error)
+	in, restore := withStdin(t)
+	defer restore()
+	defer in.Close()
+	go func() {
+		done <- CCat(readFromStdin, ColorPrinter{LightColorPalettes}, out)
+	}()
+
+	// each line is printed while the input is still open, like with
+	// tail -f app.log | ccat
+	for _, line := range []string{"started\n", "x := 1\n"} {
+		in.Write([]byte(line))
+
+		var printed string
+		timeout := time.After(5 * time.Second)
+		for !strings.HasSuffix(printed, "\n") {
+			select {
+			case s := <-out:
+				printed += s
+			case <-timeout:
+				t.Fatalf("line isn't printed: %q", printed)
+			}
+		}
+		if plain, _ := ioutil.ReadAll(stripSGR(strings.NewReader(printed))); string(plain) != line {
+			t.Errorf("output is wrong: %q", printed)
+		}
+	}
+
+	in.Close()
+	if err := <-done; err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+}
+
+// codeReader reads size bytes of source code.
+type codeReader struct {
+	size int
+	line []byte
+	n    int
+}
+
+func (c *codeReader) Read(p []byte) (int, error) {
+	if c.size <= 0 {
+		return 0, io.EOF
+	}
+	if len(p) > c.size {
+		p = p[:c.size]
+	}
+
+	n := 0
+	for n < len(p) {
+		m := copy(p[n:], c.line[c.n:])
+		n += m
+		c.n = (c.n + m) % len(c.line)
+	}
+	c.size -= n
+
+	return n, nil
+}
+
+func TestCCatStdinMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the memory ceiling test in short mode")
+	}
+
+	const size = 32 << 20
+	const ceiling = 8 << 20
+
+	var written int64
+	done := make(chan error)
+	in, restore := withStdin(t)
+	defer restore()
+	go func() {
+		done <- CCat(readFromStdin, ColorPrinter{LightColorPalettes}, countingWriter{&written})
+	}()
+
+	go func() {
+		defer in.Close()
+		io.Copy(in, &codeReader{size: size, line: []byte("if x := f(\"a\"); x > 1 { /* test */ return 'c' } // done\n")})
+	}()
+
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	base := m.HeapAlloc
+
+	var peak uint64
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("error should be nil, but it's %s", err)
+			}
+			if written < size {
+				t.Errorf("output is too short: %d bytes", written)
+			}
+			if peak > ceiling {
+				t.Errorf("memory use is too high: %d MB more for %d MB of input", peak>>20, size>>20)
+			}
+			return
+		case <-ticker.C:
+			runtime.ReadMemStats(&m)
+			if m.HeapAlloc > base && m.HeapAlloc-base > peak {
+				peak = m.HeapAlloc - base
+			}
+		}
+	}
+}
+
+type countingWriter struct {
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	*c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// rgbCodes maps color codes to the RGB values used by printers that
+// can't rely on a terminal's palette. The values follow the Tango
+// palette used by many terminal emulators by default.
+var rgbCodes = map[string]color.RGBA{
+	"black":     {0x2e, 0x34, 0x36, 0xff},
+	"darkred":   {0xcc, 0x00, 0x00, 0xff},
+	"darkgreen": {0x4e, 0x9a, 0x06, 0xff},
+	"brown":     {0xc4, 0xa0, 0x00, 0xff},
+	"darkblue":  {0x34, 0x65, 0xa4, 0xff},
+	"purple":    {0x75, 0x50, 0x7b, 0xff},
+	"teal":      {0x06, 0x98, 0x9a, 0xff},
+	"lightgray": {0xd3, 0xd7, 0xcf, 0xff},
+	"darkgray":  {0x55, 0x57, 0x53, 0xff},
+	"red":       {0xef, 0x29, 0x29, 0xff},
+	"green":     {0x8a, 0xe2, 0x34, 0xff},
+	"yellow":    {0xfc, 0xe9, 0x4f, 0xff},
+	"blue":      {0x72, 0x9f, 0xcf, 0xff},
+	"fuchsia":   {0xad, 0x7f, 0xa8, 0xff},
+	"turquoise": {0x34, 0xe2, 0xe2, 0xff},
+}
+
+func init() {
+	rgbCodes["darkteal"] = rgbCodes["turquoise"]
+	rgbCodes["darkyellow"] = rgbCodes["brown"]
+	rgbCodes["fuscia"] = rgbCodes["fuchsia"]
+	rgbCodes["lightgrey"] = rgbCodes["lightgray"]
+	rgbCodes["darkgrey"] = rgbCodes["darkgray"]
+	rgbCodes["lightgreen"] = rgbCodes["green"]
+}
+
+// themeColors returns the background and default foreground colors
+// for the given --bg value.
+func themeColors(bg string) (background, foreground color.RGBA) {
+	if bg == "dark" {
+		return color.RGBA{0x1e, 0x1e, 0x1e, 0xff}, rgbCodes["lightgray"]
+	}
+
+	return color.RGBA{0xff, 0xff, 0xff, 0xff}, rgbCodes["black"]
+}
+
+// textStyle is a color code broken down into its color and attributes.
+type textStyle struct {
+	Color     string
+	Bold      bool
+	Underline bool
+	Blink     bool
+}
+
+// parseStyle breaks down a color code in the format accepted by
+// Colorize.
+func parseStyle(attr string) textStyle {
+	var s textStyle
+
+	if strings.HasPrefix(attr, "+") && strings.HasSuffix(attr, "+") {
+		s.Blink = true
+		attr = strings.TrimPrefix(attr, "+")
+		attr = strings.TrimSuffix(attr, "+")
+	}
+
+	if strings.HasPrefix(attr, "*") && strings.HasSuffix(attr, "*") {
+		s.Bold = true
+		attr = strings.TrimPrefix(attr, "*")
+		attr = strings.TrimSuffix(attr, "*")
+	}
+
+	if strings.HasPrefix(attr, "_") && strings.HasSuffix(attr, "_") {
+		s.Underline = true
+		attr = strings.TrimPrefix(attr, "_")
+		attr = strings.TrimSuffix(attr, "_")
+	}
+
+	switch attr {
+	case "bold", "white":
+		s.Bold = true
+	case "underline":
+		s.Underline = true
+	case "blink":
+		s.Blink = true
+	default:
+		s.Color = attr
+	}
+
+	return s
+}
+
+// RGB returns the color of the style, falling back to def when the
+// style has no known color.
+func (s textStyle) RGB(def color.RGBA) color.RGBA {
+	if c, err := parseColor(s.Color); err == nil {
+		return c
+	}
+
+	return def
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// parseColor parses a color code or a hex color in the format of
+// #rrggbb.
+func parseColor(s string) (color.RGBA, error) {
+	if c, ok := rgbCodes[s]; ok {
+		return c, nil
+	}
+
+	var c color.RGBA
+	if len(s) == 7 && s[0] == '#' {
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err == nil {
+			c.R, c.G, c.B, c.A = uint8(v>>16), uint8(v>>8), uint8(v), 0xff
+			return c, nil
+		}
+	}
+
+	return c, fmt.Errorf("invalid color: %s", s)
+}
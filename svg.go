@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	svgFontSize       = 14
+	svgCellWidth      = svgFontSize * 0.6
+	svgLineHeight     = svgFontSize * 1.5
+	svgTitleBarHeight = 32
+	svgFontFamily     = `Menlo, Consolas, 'DejaVu Sans Mono', monospace`
+)
+
+func SvgPrint(src TokenSource, w io.Writer, p SvgPrinter) error {
+	lines, err := readLines(src)
+	if err != nil {
+		return err
+	}
+
+	cols := 0
+	spans := make([][]cellSpan, len(lines))
+	for i, line := range lines {
+		var n int
+		spans[i], n = layoutLine(line, p.ColorPalettes)
+		if n > cols {
+			cols = n
+		}
+	}
+
+	background, foreground := themeColors(p.BG)
+	if p.Background != "" {
+		background, err = parseColor(p.Background)
+		if err != nil {
+			return err
+		}
+	}
+
+	top := 0
+	if p.Frame {
+		top = svgTitleBarHeight
+	}
+	width := float64(2*p.Padding) + float64(cols)*svgCellWidth
+	height := float64(top+2*p.Padding) + float64(len(lines))*svgLineHeight
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+		svgNum(width), svgNum(height), svgNum(width), svgNum(height))
+	if p.Frame {
+		fmt.Fprintf(w, `<rect width="100%%" height="100%%" rx="6" fill="%s"/>`+"\n", hexColor(background))
+		for i, c := range []string{"#ff5f56", "#ffbd2e", "#27c93f"} {
+			fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="6" fill="%s"/>`+"\n", 18+i*20, svgTitleBarHeight/2, c)
+		}
+	} else {
+		fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hexColor(background))
+	}
+
+	fmt.Fprintf(w, `<text font-family="%s" font-size="%d" fill="%s" xml:space="preserve">`+"\n",
+		template.HTMLEscapeString(svgFontFamily), svgFontSize, hexColor(foreground))
+	for i, line := range spans {
+		y := float64(top+p.Padding) + float64(i)*svgLineHeight + svgFontSize
+		for _, s := range line {
+			x := float64(p.Padding) + float64(s.Col)*svgCellWidth
+			fmt.Fprintf(w, `<tspan x="%s" y="%s"%s>%s</tspan>`, svgNum(x), svgNum(y),
+				svgStyleAttrs(s.Style, foreground), template.HTMLEscapeString(s.Text))
+		}
+		if len(line) > 0 {
+			io.WriteString(w, "\n")
+		}
+	}
+	_, err = io.WriteString(w, "</text>\n</svg>\n")
+
+	return err
+}
+
+func svgStyleAttrs(s textStyle, foreground color.RGBA) string {
+	var attrs []string
+	if c := s.RGB(foreground); c != foreground {
+		attrs = append(attrs, fmt.Sprintf(`fill="%s"`, hexColor(c)))
+	}
+	if s.Bold {
+		attrs = append(attrs, `font-weight="bold"`)
+	}
+	if s.Underline {
+		attrs = append(attrs, `text-decoration="underline"`)
+	}
+
+	if len(attrs) == 0 {
+		return ""
+	}
+
+	return " " + strings.Join(attrs, " ")
+}
+
+func svgNum(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 2, 64)
+	s = strings.TrimRight(s, "0")
+
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSvgPrint(t *testing.T) {
+	r := bytes.NewBufferString("hello <world>\n")
+	var w bytes.Buffer
+
+	err := SvgPrint(Lex(r), &w, SvgPrinter{ColorPalettes: LightColorPalettes, Padding: 10})
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expect := `<svg xmlns="http://www.w3.org/2000/svg" width="129.2" height="41" viewBox="0 0 129.2 41">
+<rect width="100%" height="100%" fill="#ffffff"/>
+<text font-family="Menlo, Consolas, &#39;DejaVu Sans Mono&#39;, monospace" font-size="14" fill="#2e3436" xml:space="preserve">
+<tspan x="10" y="24" fill="#3465a4">hello</tspan><tspan x="60.4" y="24" fill="#cc0000">&lt;</tspan><tspan x="68.8" y="24" fill="#3465a4">world</tspan><tspan x="110.8" y="24" fill="#cc0000">&gt;</tspan>
+</text>
+</svg>
+`
+
+	s := w.String()
+	if s != expect {
+		t.Errorf("output is wrong: %s", s)
+	}
+}
+
+func TestLayoutLine(t *testing.T) {
+	line := []lineToken{
+		{plaintextKind.Kind, "\tab"},
+		{commentKind.Kind, "日本é"},
+	}
+
+	spans, cols := layoutLine(line, ColorPalettes{})
+	if cols != 15 {
+		t.Errorf("line should be 15 cells wide, but it's %d", cols)
+	}
+
+	var actual []string
+	for _, s := range spans {
+		actual = append(actual, strings.Repeat(" ", s.Col)+s.Text)
+	}
+
+	expect := []string{
+		"        ab",
+		"          日",
+		"            本",
+		"              é",
+	}
+	if strings.Join(actual, "|") != strings.Join(expect, "|") {
+		t.Errorf("spans are wrong: %q", actual)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package main
+
+// terminalSize isn't supported on this platform, so the size falls back
+// to $COLUMNS and $LINES.
+func terminalSize(fd uintptr) (int, int, bool) {
+	return 0, 0, false
+}
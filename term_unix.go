@@ -0,0 +1,28 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+// terminalSize returns the number of columns and rows of the terminal fd
+// is connected to.
+func terminalSize(fd uintptr) (int, int, bool) {
+	var ws winsize
+	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if err != 0 || ws.Col == 0 {
+		return 0, 0, false
+	}
+
+	return int(ws.Col), int(ws.Row), true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// whitespaceKindName is the name of syntaxhighlight.Whitespace, which
+// isn't in kinds since it can't be colored.
+const whitespaceKindName = "Whitespace"
+
+// TokenSource is the lexing stage of ccat. It feeds highlighted tokens
+// in order to p, which is the output stage, passing w along.
+type TokenSource func(w io.Writer, p syntaxhighlight.Printer) error
+
+// Lex returns a TokenSource that highlights r with the builtin lexer. r
+// is streamed, each line being printed once it's read.
+func Lex(r io.Reader) TokenSource {
+	return func(w io.Writer, p syntaxhighlight.Printer) error {
+		return lexStream(r, w, p)
+	}
+}
+
+// JsonTokens returns a TokenSource that reads tokens in the format
+// written by JsonTokensPrint from r. Only the kind and the text of a
+// token are used; positions are derived from the texts.
+func JsonTokens(r io.Reader) TokenSource {
+	return func(w io.Writer, p syntaxhighlight.Printer) error {
+		dec := json.NewDecoder(r)
+		for i := 1; ; i++ {
+			var tok jsonToken
+			err := dec.Decode(&tok)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid token #%d: %s", i, err)
+			}
+
+			k, ok := kindByName(tok.Kind)
+			if !ok {
+				return fmt.Errorf("invalid token #%d: unknown kind %q", i, tok.Kind)
+			}
+
+			err = p.Print(w, k, tok.Text)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func kindByName(name string) (syntaxhighlight.Kind, bool) {
+	if name == whitespaceKindName {
+		return syntaxhighlight.Whitespace, true
+	}
+
+	k, ok := kindsByName[name]
+
+	return k.Kind, ok
+}
+
+func kindName(k syntaxhighlight.Kind) string {
+	if k == syntaxhighlight.Whitespace {
+		return whitespaceKindName
+	}
+	// the styles of the input have no names
+	if k >= ansiKindBase {
+		return plaintextKind.Name
+	}
+
+	return kindsByKind[k].Name
+}
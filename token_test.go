@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJsonTokens(t *testing.T) {
+	r := bytes.NewBufferString("func f() {\n\treturn \"<b>\"\n}\n")
+	var tokens bytes.Buffer
+
+	err := JsonTokensPrint(Lex(r), &tokens)
+	if err != nil {
+		t.Fatalf("error should be nil, but it's %s", err)
+	}
+
+	var expect, actual bytes.Buffer
+	err = CPrint(Lex(bytes.NewBufferString("func f() {\n\treturn \"<b>\"\n}\n")), &expect, LightColorPalettes)
+	if err != nil {
+		t.Fatalf("error should be nil, but it's %s", err)
+	}
+
+	err = CPrint(JsonTokens(&tokens), &actual, LightColorPalettes)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	if actual.String() != expect.String() {
+		t.Errorf("output is wrong: %q", actual.String())
+	}
+}
+
+func TestJsonTokensUnknownKind(t *testing.T) {
+	r := bytes.NewBufferString(`{"kind":"Keyword","text":"if"}` + "\n" + `{"kind":"Operator","text":"+"}`)
+	var w bytes.Buffer
+
+	err := CPrint(JsonTokens(r), &w, LightColorPalettes)
+	if err == nil || err.Error() != `invalid token #2: unknown kind "Operator"` {
+		t.Errorf("error is wrong: %v", err)
+	}
+}
+
+// upperPrinter prints the text it reads in upper case, and can't print
+// tokens.
+type upperPrinter struct{}
+
+func (upperPrinter) Print(r io.Reader, w io.Writer) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bytes.ToUpper(b))
+	return err
+}
+
+func TestPrintTokensText(t *testing.T) {
+	r := bytes.NewBufferString(`{"kind":"Keyword","text":"if"}` + "\n" + `{"kind":"Whitespace","text":" "}` + "\n" + `{"kind":"Plaintext","text":"x"}`)
+	var w bytes.Buffer
+
+	err := printTokens(&WhitespacePrinter{Printer: upperPrinter{}, Tabs: 4}, JsonTokens(r), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+	if w.String() != "IF X" {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestRenderArgs(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected string
+		Render   bool
+	}{
+		{"", "", false},
+		{"main.go", "", false},
+		{"render", "", true},
+		{"render --from-tokens --html FILE", "--from-tokens --html FILE", true},
+		{"-C always render --from-tokens", "-C always --from-tokens", true},
+		{"--bg dark -n render FILE", "--bg dark -n FILE", true},
+		{"FILE render", "", false},
+		{"-- render", "", false},
+		{"-C render FILE", "", false},
+		{"--unknown render", "", false},
+	}
+
+	for _, tc := range cases {
+		args, ok := renderArgs(strings.Fields(tc.Input))
+		if ok != tc.Render || strings.Join(args, " ") != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %q %t\n\nExpected: %q %t", tc.Input, args, ok, tc.Expected, tc.Render)
+		}
+	}
+}
+
+func TestRenderArgsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// a file named render is printed rather than taken for the command
+	if err := ioutil.WriteFile(filepath.Join(dir, "render"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := renderArgs([]string{"render"}); ok {
+		t.Errorf("render is taken for the command")
+	}
+}
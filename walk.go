@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// vcsDirs are the directories of version control systems, which are
+// never walked into.
+var vcsDirs = map[string]bool{".git": true, ".hg": true, ".svn": true}
+
+// ignoreFiles are read in each directory, the patterns of later ones
+// taking precedence.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// WalkOptions select the files WalkFiles finds.
+type WalkOptions struct {
+	Include  []string
+	Exclude  []string
+	MaxDepth int
+}
+
+// WalkFiles returns the text files in the directory root and its
+// subdirectories in lexical order. What .gitignore and .ignore files
+// ignore, including those above root in its git repository and the
+// repository's info/exclude, or Exclude matches is skipped, as well as the files Include
+// doesn't match if it isn't empty. The files directly in root are at
+// depth 1, and directories aren't walked below MaxDepth unless it's 0.
+//
+// Globs with a / are matched against the path within root, and others
+// against the name of a file. ** matches any number of directories.
+//
+// A directory that can't be read is skipped, and the *FileError of the
+// first one is returned along with the files of the others.
+func WalkFiles(root string, opts WalkOptions) ([]string, error) {
+	for _, g := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob: %s", g)
+		}
+	}
+
+	w := &walker{opts: opts}
+	var ignores []ignorePattern
+	w.prefix, ignores = repoIgnores(root)
+	w.walk(root, "", 1, ignores)
+
+	return w.files, w.err
+}
+
+type walker struct {
+	opts WalkOptions
+	// prefix is the path of the root within its repository, which the
+	// ignore patterns are matched against
+	prefix string
+	files  []string
+	err    error
+}
+
+// walk adds the files in dir, which is at rel within the root, with the
+// ignore patterns of its parents.
+func (w *walker) walk(dir, rel string, depth int, ignores []ignorePattern) {
+	ignores = append(ignores[:len(ignores):len(ignores)], readIgnoreFiles(dir, path.Join(w.prefix, rel))...)
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if w.err == nil {
+			w.err = &FileError{dir, err}
+		}
+		return
+	}
+
+	for _, fi := range entries {
+		fpath := filepath.Join(dir, fi.Name())
+		frel := path.Join(rel, fi.Name())
+
+		// links to files are followed, but not links to directories, so
+		// that walks can't loop
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(fpath)
+			if err != nil || target.IsDir() {
+				continue
+			}
+			fi = target
+		}
+
+		if fi.IsDir() && vcsDirs[fi.Name()] {
+			continue
+		}
+		if isIgnored(ignores, path.Join(w.prefix, frel), fi.IsDir()) || matchAnyGlob(w.opts.Exclude, frel) {
+			continue
+		}
+
+		if fi.IsDir() {
+			if w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+				continue
+			}
+			w.walk(fpath, frel, depth+1, ignores)
+			continue
+		}
+
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if len(w.opts.Include) > 0 && !matchAnyGlob(w.opts.Include, frel) {
+			continue
+		}
+		if isBinaryFile(fpath) {
+			continue
+		}
+
+		w.files = append(w.files, fpath)
+	}
+}
+
+// isBinaryFile tells whether the content of fname, decompressed if need
+// be, is binary.
+func isBinaryFile(fname string) bool {
+	file, err := os.Open(fname)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	r, _, err := decompress(file)
+	if err != nil {
+		return true
+	}
+	head, _ := bufio.NewReaderSize(r, sniffLen).Peek(sniffLen)
+
+	return isBinaryHead(head)
+}
+
+// isBinaryHead tells whether the content starting with head is binary,
+// rather than text in an encoding other than UTF-8.
+func isBinaryHead(head []byte) bool {
+	if enc := detectEncoding(head); enc != "" && enc != "utf-8" {
+		return false
+	}
+	_, binary := detectBinary(head)
+
+	return binary
+}
+
+// ignorePattern is a line of an ignore file in the directory base within
+// the root, with the syntax of .gitignore.
+type ignorePattern struct {
+	base     string
+	glob     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// repoIgnores returns the path of root within its git repository and the
+// patterns of the repository that apply to it from above: its
+// info/exclude and the ignore files of the directories above root.
+func repoIgnores(root string) (string, []ignorePattern) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", nil
+	}
+	// the repository of the files in root
+	repo, err := findGitRepo(filepath.Join(abs, ignoreFiles[0]))
+	if err != nil {
+		return "", nil
+	}
+	rel, err := filepath.Rel(repo.WorkTree, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", nil
+	}
+
+	var patterns []ignorePattern
+	if data, err := ioutil.ReadFile(filepath.Join(repo.CommonDir, "info", "exclude")); err == nil {
+		patterns = parseIgnore(string(data), "")
+	}
+	if rel == "." {
+		return "", patterns
+	}
+
+	prefix := filepath.ToSlash(rel)
+	dir, base := repo.WorkTree, ""
+	for _, name := range strings.Split(prefix, "/") {
+		patterns = append(patterns, readIgnoreFiles(dir, base)...)
+		dir, base = filepath.Join(dir, name), path.Join(base, name)
+	}
+
+	return prefix, patterns
+}
+
+func readIgnoreFiles(dir, rel string) []ignorePattern {
+	var patterns []ignorePattern
+	for _, name := range ignoreFiles {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, name)); err == nil {
+			patterns = append(patterns, parseIgnore(string(data), rel)...)
+		}
+	}
+
+	return patterns
+}
+
+// parseIgnore parses the patterns of an ignore file in base.
+func parseIgnore(s, base string) []ignorePattern {
+	var patterns []ignorePattern
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		p := ignorePattern{base: base}
+		if line[0] == '!' {
+			p.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		// a / anywhere but at the end ties a pattern to base
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		p.glob = line
+		patterns = append(patterns, p)
+	}
+
+	return patterns
+}
+
+func (p ignorePattern) match(rel string, dir bool) bool {
+	if p.dirOnly && !dir {
+		return false
+	}
+
+	name := rel
+	if p.base != "" {
+		if !strings.HasPrefix(rel, p.base+"/") {
+			return false
+		}
+		name = rel[len(p.base)+1:]
+	}
+	if !p.anchored {
+		name = path.Base(name)
+	}
+
+	return matchGlob(p.glob, name)
+}
+
+// isIgnored tells whether the last of patterns that matches rel ignores
+// it rather than negating an earlier pattern.
+func isIgnored(patterns []ignorePattern, rel string, dir bool) bool {
+	ignored := false
+	for _, p := range patterns {
+		if p.match(rel, dir) {
+			ignored = !p.negate
+		}
+	}
+
+	return ignored
+}
+
+func matchAnyGlob(globs []string, rel string) bool {
+	for _, g := range globs {
+		name := rel
+		if !strings.Contains(g, "/") {
+			name = path.Base(rel)
+		}
+		if matchGlob(g, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchGlob matches a / separated name against glob, where ** matches any
+// number of path elements.
+func matchGlob(glob, name string) bool {
+	return matchGlobElems(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobElems(globs, names []string) bool {
+	for len(globs) > 0 {
+		if globs[0] == "**" {
+			globs = globs[1:]
+			for i := 0; i <= len(names); i++ {
+				if matchGlobElems(globs, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(globs[0], names[0]); !ok {
+			return false
+		}
+		globs, names = globs[1:], names[1:]
+	}
+
+	return len(names) == 0
+}
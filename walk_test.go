@@ -0,0 +1,165 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		".gitignore":        "build/\n*.log\n!keep.log\n/top.txt\n",
+		"a.go":              "package a\n",
+		"top.txt":           "ignored at the top\n",
+		"x.log":             "ignored\n",
+		"keep.log":          "kept\n",
+		"image.png":         "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"build/out.go":      "ignored\n",
+		".git/config":       "[core]\n",
+		"pkg/.ignore":       "sub/deep\n",
+		"pkg/b.go":          "package b\n",
+		"pkg/top.txt":       "not at the top\n",
+		"pkg/ja.txt":        "\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd\n",
+		"pkg/utf16.txt":     "\xff\xfeh\x00i\x00\n\x00",
+		"pkg/sub/c.go":      "package c\n",
+		"pkg/sub/deep/d.go": "ignored\n",
+		"vendor/x/v.go":     "package x\n",
+	} {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		Options  WalkOptions
+		Expected []string
+	}{
+		{WalkOptions{}, []string{".gitignore", "a.go", "keep.log", "pkg/.ignore", "pkg/b.go", "pkg/ja.txt", "pkg/sub/c.go", "pkg/top.txt", "pkg/utf16.txt", "vendor/x/v.go"}},
+		{WalkOptions{Include: []string{"*.go"}, Exclude: []string{"vendor"}}, []string{"a.go", "pkg/b.go", "pkg/sub/c.go"}},
+		{WalkOptions{Include: []string{"pkg/**/*.go"}}, []string{"pkg/b.go", "pkg/sub/c.go"}},
+		{WalkOptions{Exclude: []string{"*.go", ".*"}}, []string{"keep.log", "pkg/ja.txt", "pkg/top.txt", "pkg/utf16.txt"}},
+		{WalkOptions{Include: []string{"*.go"}, MaxDepth: 2}, []string{"a.go", "pkg/b.go"}},
+		{WalkOptions{MaxDepth: 1}, []string{".gitignore", "a.go", "keep.log"}},
+	}
+
+	for _, tc := range cases {
+		files, err := WalkFiles(dir, tc.Options)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		var rels []string
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(rels, tc.Expected) {
+			t.Errorf("Options: %+v\n\nOutput: %q\n\nExpected: %q", tc.Options, rels, tc.Expected)
+		}
+	}
+
+	if _, err := WalkFiles(dir, WalkOptions{Include: []string{"[a-"}}); err == nil {
+		t.Errorf("error shouldn't be nil")
+	}
+}
+
+func TestWalkFilesRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		".git/info/exclude":  "*.tmp\n",
+		".gitignore":         "gen/\n/pkg/skip.go\nsub/*.log\n",
+		"pkg/.gitignore":     "!keep.tmp\n",
+		"pkg/a.go":           "package a\n",
+		"pkg/skip.go":        "ignored\n",
+		"pkg/x.tmp":          "ignored\n",
+		"pkg/keep.tmp":       "kept\n",
+		"pkg/gen/g.go":       "ignored\n",
+		"pkg/sub/b.go":       "package b\n",
+		"pkg/sub/x.log":      "kept, the pattern has a /\n",
+		"pkg/sub/sub/x.log":  "kept\n",
+		"pkg/sub/gen/out.go": "ignored\n",
+	} {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		Root     string
+		Expected []string
+	}{
+		{"pkg", []string{".gitignore", "a.go", "keep.tmp", "sub/b.go", "sub/sub/x.log", "sub/x.log"}},
+		{"pkg/sub", []string{"b.go", "sub/x.log", "x.log"}},
+	}
+
+	for _, tc := range cases {
+		root := filepath.Join(dir, filepath.FromSlash(tc.Root))
+		files, err := WalkFiles(root, WalkOptions{})
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		var rels []string
+		for _, f := range files {
+			rel, _ := filepath.Rel(root, f)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(rels, tc.Expected) {
+			t.Errorf("Root: %s\n\nOutput: %q\n\nExpected: %q", tc.Root, rels, tc.Expected)
+		}
+	}
+}
+
+func TestIgnorePattern(t *testing.T) {
+	cases := []struct {
+		Ignore   string
+		Base     string
+		Path     string
+		Dir      bool
+		Expected bool
+	}{
+		{"*.log", "", "logs/app.log", false, true},
+		{"*.log\n!keep.log", "", "keep.log", false, false},
+		{"build/", "", "build", true, true},
+		{"build/", "", "build", false, false},
+		{"/top.txt", "", "top.txt", false, true},
+		{"/top.txt", "", "pkg/top.txt", false, false},
+		{"doc/*.md", "", "doc/a.md", false, true},
+		{"doc/*.md", "", "doc/sub/a.md", false, false},
+		{"**/testdata", "", "a/b/testdata", true, true},
+		{"a/**/b", "", "a/x/y/b", false, true},
+		{"a/**/b", "", "a/b", false, true},
+		{"sub", "pkg", "pkg/sub", true, true},
+		{"sub", "pkg", "other/sub", true, false},
+		{"# comment\n\\#file", "", "#file", false, true},
+		{"trailing   \r\n", "", "trailing", false, true},
+	}
+
+	for _, tc := range cases {
+		ignored := isIgnored(parseIgnore(tc.Ignore, tc.Base), tc.Path, tc.Dir)
+		if ignored != tc.Expected {
+			t.Errorf("Ignore: %q\n\nPath: %s\n\nOutput: %v", tc.Ignore, tc.Path, ignored)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// Glyphs of --show-whitespace.
+const (
+	tabGlyph           = "→"
+	trailingSpaceGlyph = "·"
+	nbspGlyph          = "⍽"
+	crGlyph            = "␍"
+	lfGlyph            = "␊"
+)
+
+var zeroWidthNames = map[rune]string{
+	'\u200b': "ZWSP",
+	'\u200c': "ZWNJ",
+	'\u200d': "ZWJ",
+	'\u2060': "WJ",
+	'\ufeff': "BOM",
+}
+
+// WhitespacePrinter expands tabs to the tab stops every Tabs columns, if
+// it's set, and with Show renders whitespace that's hard to see as glyphs
+// of the Invisible kind: tabs, trailing spaces, non-breaking
+// spaces, zero-width characters, CRs and LFs.
+//
+// Columns are counted from the start of the line of the file, so wide
+// characters take two and combining marks none.
+type WhitespacePrinter struct {
+	Printer CCatPrinter
+	Tabs    int
+	Show    bool
+}
+
+func (p *WhitespacePrinter) SetFile(file File) {
+	if f, ok := p.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (p *WhitespacePrinter) Print(r io.Reader, w io.Writer) error {
+	return p.PrintTokens(Lex(r), w)
+}
+
+func (p *WhitespacePrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(p.Printer, func(w io.Writer, cp syntaxhighlight.Printer) error {
+		wp := &whitespaceCodePrinter{WhitespacePrinter: p, p: cp}
+		if err := src(w, wp); err != nil {
+			return err
+		}
+		if err := wp.flushBlanks(w, true); err != nil {
+			return err
+		}
+
+		return wp.run.Flush(w, wp.p)
+	}, w)
+}
+
+type whitespaceCodePrinter struct {
+	*WhitespacePrinter
+	p syntaxhighlight.Printer
+
+	col int
+	run tokenRun
+
+	// blanks are the spaces, tabs and CRs that may be trailing
+	blanks []lineToken
+}
+
+func (p *whitespaceCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	for len(tokText) > 0 {
+		r, size := utf8.DecodeRuneInString(tokText)
+		text := tokText[:size]
+		tokText = tokText[size:]
+
+		if r == ' ' || r == '\t' || r == '\r' {
+			p.blanks = append(p.blanks, lineToken{kind, text})
+			continue
+		}
+
+		if err := p.flushBlanks(w, r == '\n'); err != nil {
+			return err
+		}
+
+		if r == '\n' {
+			if p.Show {
+				if err := p.add(w, Invisible, lfGlyph); err != nil {
+					return err
+				}
+			}
+			if err := p.add(w, syntaxhighlight.Whitespace, text); err != nil {
+				return err
+			}
+			p.col = 0
+			continue
+		}
+
+		if name, ok := zeroWidthNames[r]; ok && p.Show {
+			kind, text = Invisible, fmt.Sprintf("<%s>", name)
+		} else if r == '\u00a0' && p.Show {
+			kind, text = Invisible, nbspGlyph
+		}
+
+		p.col += stringWidth(text)
+		if err := p.add(w, kind, text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flushBlanks prints the blanks before a character, or before the end
+// of the line if trailing is set.
+func (p *whitespaceCodePrinter) flushBlanks(w io.Writer, trailing bool) error {
+	blanks := p.blanks
+	p.blanks = p.blanks[:0]
+
+	for _, b := range blanks {
+		kind, text := b.Kind, b.Text
+		switch {
+		case text == "\t" && (p.Tabs > 0 || p.Show):
+			n := tabStop(p.col, p.Tabs)
+			p.col += n
+			text = strings.Repeat(" ", n)
+			if p.Show {
+				kind, text = Invisible, tabGlyph+text[1:]
+			}
+		case text == "\t":
+			p.col += tabStop(p.col, defaultTabWidth)
+		case text == "\r":
+			if p.Show {
+				kind, text = Invisible, crGlyph
+				p.col++
+			}
+		case p.Show && trailing:
+			kind, text = Invisible, trailingSpaceGlyph
+			p.col++
+		default:
+			p.col++
+		}
+
+		if err := p.add(w, kind, text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *whitespaceCodePrinter) add(w io.Writer, kind syntaxhighlight.Kind, text string) error {
+	return p.run.Add(w, p.p, kind, text)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWhitespacePrinter(t *testing.T) {
+	cases := []struct {
+		Tabs     int
+		Show     bool
+		Input    string
+		Expected string
+	}{
+		{
+			Tabs:     4,
+			Input:    "a\tb\n\tc  \n",
+			Expected: "a   b\n    c  \n",
+		},
+		{
+			// wide characters take two columns before the tab stop
+			Tabs:     4,
+			Input:    "日本語\tx\n",
+			Expected: "日本語  x\n",
+		},
+		{
+			Show:     true,
+			Input:    "a\tb  \r\n",
+			Expected: "a→      b··␍␊\n",
+		},
+		{
+			Tabs:     2,
+			Show:     true,
+			Input:    "x y\u00a0z\u200b\n\t \n",
+			Expected: "x y⍽z<ZWSP>␊\n→ ·␊\n",
+		},
+		{
+			Show:     true,
+			Input:    "no newline  ",
+			Expected: "no newline··",
+		},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		p := &WhitespacePrinter{Printer: PlainTextPrinter{}, Tabs: tc.Tabs, Show: tc.Show}
+
+		err := p.Print(bytes.NewBufferString(tc.Input), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		if w.String() != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %q\n\nExpected: %q", tc.Input, w.String(), tc.Expected)
+		}
+	}
+}
+
+func TestWhitespacePrinterKind(t *testing.T) {
+	var w bytes.Buffer
+	p := &WhitespacePrinter{Printer: ColorPrinter{ColorPalettes{invisibleKind: "darkgray"}}, Show: true}
+	err := p.Print(bytes.NewBufferString("x \n"), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	expect := "x\033[30;01m·␊\033[39;49;00m\n"
+	if w.String() != expect {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestRuneWidth(t *testing.T) {
+	cases := []struct {
+		Input    rune
+		Expected int
+	}{
+		{'a', 1},
+		{'é', 1},
+		{'\u0301', 0},
+		{'\u200b', 0},
+		{'世', 2},
+		{'ア', 2},
+		{'ｱ', 1},
+		{'　', 2},
+		{'Ａ', 2},
+		{'가', 2},
+		{'😀', 2},
+		{'🚀', 2},
+		{'🛸', 2},
+		{'🥑', 2},
+		{'🫠', 2},
+		{'⌚', 2},
+		{'☀', 1},
+		{'𠀀', 2},
+	}
+
+	for _, tc := range cases {
+		if w := runeWidth(tc.Input); w != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %d\n\nExpected: %d", tc.Input, w, tc.Expected)
+		}
+	}
+}
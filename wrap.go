@@ -0,0 +1,271 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+const wrapMarker = "↪"
+
+// WrapPrinter breaks lines that are wider than Width. Every piece of a
+// broken line is a token of its own, so the output printer closes the
+// colors of a token before a break and reopens them after it.
+//
+// Lines start with Indent cells of decorations like the gutter. Their
+// continuation lines are indented as much, with the bar of the gutter
+// repeated at column Bar unless it's 0, and get a wrap marker hanging
+// after the indentation of the first line.
+type WrapPrinter struct {
+	Printer CCatPrinter
+	Width   int
+	Words   bool
+	Indent  int
+	Bar     int
+}
+
+func (p *WrapPrinter) SetFile(file File) {
+	if f, ok := p.Printer.(FilePrinter); ok {
+		f.SetFile(file)
+	}
+}
+
+func (p *WrapPrinter) Print(r io.Reader, w io.Writer) error {
+	return p.PrintTokens(Lex(r), w)
+}
+
+func (p *WrapPrinter) PrintTokens(src TokenSource, w io.Writer) error {
+	return printTokens(p.Printer, func(w io.Writer, cp syntaxhighlight.Printer) error {
+		wp := &wrapCodePrinter{WrapPrinter: p, p: cp, minCol: p.Indent}
+		if err := src(w, wp); err != nil {
+			return err
+		}
+		if err := wp.flushWord(w); err != nil {
+			return err
+		}
+
+		return wp.flushRun(w)
+	}, w)
+}
+
+type wrapCodePrinter struct {
+	*WrapPrinter
+	p syntaxhighlight.Printer
+
+	col     int
+	minCol  int
+	content bool
+	lead    int
+
+	// run collects the text of the same kind up to the next break
+	run tokenRun
+
+	// word collects the text up to the next space in word mode
+	word      []lineToken
+	wordWidth int
+}
+
+func (p *wrapCodePrinter) Print(w io.Writer, kind syntaxhighlight.Kind, tokText string) error {
+	for len(tokText) > 0 {
+		r, size := utf8.DecodeRuneInString(tokText)
+		text := tokText[:size]
+		tokText = tokText[size:]
+
+		var err error
+		switch {
+		case r == '\n':
+			err = p.endLine(w)
+		case r == ' ' || r == '\t':
+			if err = p.flushWord(w); err == nil {
+				err = p.printSpace(w, kind, text)
+			}
+		case p.Words:
+			err = p.addToWord(w, kind, text)
+		default:
+			err = p.printText(w, kind, text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *wrapCodePrinter) endLine(w io.Writer) error {
+	if err := p.flushWord(w); err != nil {
+		return err
+	}
+	if err := p.flushRun(w); err != nil {
+		return err
+	}
+
+	p.col = 0
+	p.minCol = p.Indent
+	p.content = false
+	p.lead = 0
+
+	return p.p.Print(w, syntaxhighlight.Whitespace, "\n")
+}
+
+// printSpace prints a space or a tab, unless it's where the line breaks.
+func (p *wrapCodePrinter) printSpace(w io.Writer, kind syntaxhighlight.Kind, text string) error {
+	n := 1
+	if text == "\t" {
+		n = tabStop(p.col, defaultTabWidth)
+	}
+
+	if p.col+n > p.Width && p.col > p.minCol {
+		return p.breakLine(w)
+	}
+
+	p.col += n
+
+	return p.add(w, kind, text)
+}
+
+// printText prints a character, breaking the line before it if it
+// doesn't fit anymore.
+func (p *wrapCodePrinter) printText(w io.Writer, kind syntaxhighlight.Kind, text string) error {
+	n := stringWidth(text)
+	if !p.content && p.col >= p.Indent {
+		p.content = true
+		p.lead = p.col - p.Indent
+	}
+
+	if p.col+n > p.Width && p.col > p.minCol {
+		if err := p.breakLine(w); err != nil {
+			return err
+		}
+	}
+
+	p.col += n
+
+	return p.add(w, kind, text)
+}
+
+func (p *wrapCodePrinter) addToWord(w io.Writer, kind syntaxhighlight.Kind, text string) error {
+	if n := len(p.word); n > 0 && p.word[n-1].Kind == kind {
+		p.word[n-1].Text += text
+	} else {
+		p.word = append(p.word, lineToken{kind, text})
+	}
+	p.wordWidth += stringWidth(text)
+
+	// a word that doesn't even fit on a line of its own is broken anywhere
+	if p.wordWidth > p.Width-p.continuationCol() {
+		return p.flushWord(w)
+	}
+
+	return nil
+}
+
+// flushWord prints the word collected so far, on the next line if it
+// doesn't fit on the current one but on a continuation line.
+func (p *wrapCodePrinter) flushWord(w io.Writer) error {
+	if len(p.word) == 0 {
+		return nil
+	}
+
+	word := p.word
+	p.word = nil
+	fits := p.wordWidth <= p.Width-p.continuationCol()
+	if fits && p.col+p.wordWidth > p.Width && p.col > p.minCol && p.col >= p.Indent {
+		if err := p.breakLine(w); err != nil {
+			return err
+		}
+	}
+	p.wordWidth = 0
+
+	for _, tok := range word {
+		for _, r := range tok.Text {
+			if err := p.printText(w, tok.Kind, string(r)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *wrapCodePrinter) add(w io.Writer, kind syntaxhighlight.Kind, text string) error {
+	return p.run.Add(w, p.p, kind, text)
+}
+
+func (p *wrapCodePrinter) flushRun(w io.Writer) error {
+	return p.run.Flush(w, p.p)
+}
+
+// hang returns the indentation of continuation lines after Indent.
+func (p *wrapCodePrinter) hang() int {
+	hang := p.lead
+	if max := (p.Width - p.Indent) / 2; hang > max {
+		hang = max
+	}
+
+	return hang
+}
+
+func (p *wrapCodePrinter) continuationCol() int {
+	return p.Indent + p.hang() + stringWidth(wrapMarker) + 1
+}
+
+func (p *wrapCodePrinter) breakLine(w io.Writer) error {
+	if err := p.flushRun(w); err != nil {
+		return err
+	}
+	if err := p.p.Print(w, syntaxhighlight.Whitespace, "\n"); err != nil {
+		return err
+	}
+
+	if p.Bar > 0 && p.Bar < p.Indent {
+		if err := p.p.Print(w, syntaxhighlight.Whitespace, strings.Repeat(" ", p.Bar)); err != nil {
+			return err
+		}
+		if err := p.p.Print(w, LineNumber, "│"); err != nil {
+			return err
+		}
+		if err := p.p.Print(w, syntaxhighlight.Whitespace, strings.Repeat(" ", p.Indent-p.Bar-1+p.hang())); err != nil {
+			return err
+		}
+	} else if n := p.Indent + p.hang(); n > 0 {
+		if err := p.p.Print(w, syntaxhighlight.Whitespace, strings.Repeat(" ", n)); err != nil {
+			return err
+		}
+	}
+
+	if err := p.p.Print(w, Marker, wrapMarker); err != nil {
+		return err
+	}
+	if err := p.p.Print(w, syntaxhighlight.Whitespace, " "); err != nil {
+		return err
+	}
+
+	p.col = p.continuationCol()
+	p.minCol = p.col
+
+	return nil
+}
+
+// lineIndent returns the width of what's printed before the text of each
+// line and the column of the bar of the gutter, or 0 if there's no
+// gutter. The marker of a target line comes first, then the gutter and
+// then the line number of -n.
+func lineIndent(d Decorations, cat CatOptions, targetMarker bool) (int, int) {
+	indent, bar := 0, 0
+	if targetMarker {
+		indent += 2
+	}
+	if d.Gutter() {
+		bar = indent + d.GutterWidth()
+		indent = bar + 2
+	}
+	if cat.Number || cat.NumberNonblank {
+		indent += 6
+		indent += tabStop(indent, defaultTabWidth)
+	}
+
+	return indent, bar
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrapPrinter(t *testing.T) {
+	cases := []struct {
+		Printer  WrapPrinter
+		Input    string
+		Expected string
+	}{
+		{
+			Printer:  WrapPrinter{Width: 10},
+			Input:    "abcdefghijklmn\nshort\n",
+			Expected: "abcdefghij\n↪ klmn\nshort\n",
+		},
+		{
+			Printer:  WrapPrinter{Width: 10, Words: true},
+			Input:    "one two three four",
+			Expected: "one two \n↪ three \n↪ four",
+		},
+		{
+			Printer:  WrapPrinter{Width: 9, Words: true},
+			Input:    "  aaa bbb ccc",
+			Expected: "  aaa bbb\n  ↪ ccc",
+		},
+		{
+			// wide characters aren't split across lines
+			Printer:  WrapPrinter{Width: 7},
+			Input:    "日本語の文章",
+			Expected: "日本語\n↪ の文\n↪ 章",
+		},
+		{
+			// combining marks stay with their base character
+			Printer:  WrapPrinter{Width: 3},
+			Input:    "abécd",
+			Expected: "abé\n↪ c\n↪ d",
+		},
+		{
+			Printer:  WrapPrinter{Width: 12, Indent: 4, Bar: 2},
+			Input:    "1 │ abcdefghijkl",
+			Expected: "1 │ abcdefgh\n  │ ↪ ijkl",
+		},
+	}
+
+	for _, tc := range cases {
+		var w bytes.Buffer
+		p := tc.Printer
+		p.Printer = PlainTextPrinter{}
+
+		err := p.Print(bytes.NewBufferString(tc.Input), &w)
+		if err != nil {
+			t.Errorf("error should be nil, but it's %s", err)
+		}
+
+		if w.String() != tc.Expected {
+			t.Errorf("Input: %q\n\nOutput: %q\n\nExpected: %q", tc.Input, w.String(), tc.Expected)
+		}
+	}
+}
+
+func TestWrapPrinterColors(t *testing.T) {
+	var w bytes.Buffer
+	p := &WrapPrinter{Printer: ColorPrinter{ColorPalettes{stringKind: "brown"}}, Width: 6}
+	err := p.Print(bytes.NewBufferString(`"abcdefg"`), &w)
+	if err != nil {
+		t.Errorf("error should be nil, but it's %s", err)
+	}
+
+	// the string is closed before the break and reopened after it
+	expect := "\033[33m\"abcde\033[39;49;00m\n↪ \033[33mfg\"\033[39;49;00m"
+	if w.String() != expect {
+		t.Errorf("output is wrong: %q", w.String())
+	}
+}
+
+func TestLineIndent(t *testing.T) {
+	cases := []struct {
+		Decorations  Decorations
+		Cat          CatOptions
+		TargetMarker bool
+		Indent, Bar  int
+	}{
+		{Decorations{}, CatOptions{}, false, 0, 0},
+		{Decorations{Numbers: true}, CatOptions{}, false, 7, 5},
+		{Decorations{Numbers: true, Changes: true}, CatOptions{}, false, 9, 7},
+		{Decorations{}, CatOptions{Number: true}, false, 8, 0},
+		{Decorations{}, CatOptions{Number: true}, true, 16, 0},
+		{Decorations{Numbers: true}, CatOptions{Number: true}, false, 16, 5},
+	}
+
+	for _, tc := range cases {
+		indent, bar := lineIndent(tc.Decorations, tc.Cat, tc.TargetMarker)
+		if indent != tc.Indent || bar != tc.Bar {
+			t.Errorf("%+v %+v: indent is %d and bar %d", tc.Decorations, tc.Cat, indent, bar)
+		}
+	}
+}
@@ -0,0 +1,811 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+// The xz reader decodes the .xz container with the LZMA2 filter, which
+// is what xz writes unless told to use other filters. It follows the
+// xz file format 1.0.4 and the LZMA decoder of the LZMA SDK.
+
+const (
+	xzMagic       = "\xfd7zXZ\x00"
+	xzFooterMagic = "YZ"
+	xzLZMA2       = 0x21
+	xzMaxDictSize = 1 << 30
+
+	lzmaStates       = 12
+	lzmaPosStatesMax = 1 << 4
+	lzmaLenLow       = 8
+	lzmaLenMid       = 8
+	lzmaLenHigh      = 256
+	lzmaDistStates   = 4
+	lzmaDistSlots    = 64
+	lzmaDistModelEnd = 14
+	lzmaFullDists    = 1 << (lzmaDistModelEnd / 2)
+	lzmaAlignBits    = 4
+	lzmaMatchMinLen  = 2
+	lzmaProbInit     = 1 << 10
+)
+
+var errXZCorrupt = errors.New("xz: corrupt data")
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// xzReader decompresses the streams of an .xz file one LZMA2 chunk at a
+// time.
+type xzReader struct {
+	r *xzByteReader
+
+	lz      *lzma2Decoder
+	check   byte
+	hash    hash.Hash
+	inBlock bool
+	done    bool
+
+	// out holds what's been decoded but not read yet
+	out []byte
+}
+
+// xzByteReader counts the bytes read, which the padding of blocks
+// depends on.
+type xzByteReader struct {
+	br *bufio.Reader
+	n  int64
+}
+
+func (b *xzByteReader) ReadByte() (byte, error) {
+	c, err := b.br.ReadByte()
+	if err == nil {
+		b.n++
+	}
+
+	return c, err
+}
+
+func (b *xzByteReader) Read(p []byte) (int, error) {
+	n, err := io.ReadFull(b.br, p)
+	b.n += int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+func newXZReader(r io.Reader) (io.Reader, error) {
+	x := &xzReader{r: &xzByteReader{br: bufio.NewReader(r)}}
+	if err := x.readStreamHeader(); err != nil {
+		return nil, err
+	}
+
+	return x, nil
+}
+
+func (x *xzReader) Read(p []byte) (int, error) {
+	for len(x.out) == 0 {
+		if x.done {
+			return 0, io.EOF
+		}
+		if err := x.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, x.out)
+	x.out = x.out[n:]
+
+	return n, nil
+}
+
+// next decodes the next chunk of a block, or moves on to the next block
+// or stream.
+func (x *xzReader) next() error {
+	if !x.inBlock {
+		return x.readBlockHeader()
+	}
+
+	out, err := x.lz.decodeChunk(x.r)
+	if err == io.EOF {
+		x.inBlock = false
+		return x.readBlockEnd()
+	}
+	if err != nil {
+		return err
+	}
+
+	if x.hash != nil {
+		x.hash.Write(out)
+	}
+	x.out = out
+
+	return nil
+}
+
+func (x *xzReader) readStreamHeader() error {
+	var h [12]byte
+	if _, err := x.r.Read(h[:]); err != nil {
+		return err
+	}
+	if string(h[:6]) != xzMagic {
+		return errors.New("xz: invalid header")
+	}
+	if crc32.ChecksumIEEE(h[6:8]) != binary.LittleEndian.Uint32(h[8:]) {
+		return errXZCorrupt
+	}
+	if h[6] != 0 || h[7] > 0x0f {
+		return errors.New("xz: unsupported stream flags")
+	}
+	x.check = h[7]
+
+	return nil
+}
+
+func (x *xzReader) readBlockHeader() error {
+	size, err := x.r.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if size == 0 {
+		return x.readIndex()
+	}
+
+	h := make([]byte, (int(size)+1)*4)
+	h[0] = size
+	if _, err := x.r.Read(h[1:]); err != nil {
+		return err
+	}
+	n := len(h) - 4
+	if crc32.ChecksumIEEE(h[:n]) != binary.LittleEndian.Uint32(h[n:]) {
+		return errXZCorrupt
+	}
+
+	// the sizes are optional and only checked by the index
+	flags := h[1]
+	br := bytes.NewReader(h[2:n])
+	if flags&0x40 != 0 {
+		if _, err := binary.ReadUvarint(br); err != nil {
+			return errXZCorrupt
+		}
+	}
+	if flags&0x80 != 0 {
+		if _, err := binary.ReadUvarint(br); err != nil {
+			return errXZCorrupt
+		}
+	}
+
+	if flags&0x03 != 0 {
+		return errors.New("xz: unsupported filters")
+	}
+	id, err := binary.ReadUvarint(br)
+	if err != nil {
+		return errXZCorrupt
+	}
+	propsLen, err := binary.ReadUvarint(br)
+	if err != nil {
+		return errXZCorrupt
+	}
+	if id != xzLZMA2 || propsLen != 1 {
+		return errors.New("xz: unsupported filters")
+	}
+	props, err := br.ReadByte()
+	if err != nil {
+		return errXZCorrupt
+	}
+	dictSize, err := lzma2DictSize(props)
+	if err != nil {
+		return err
+	}
+
+	x.lz = newLZMA2Decoder(dictSize)
+	x.hash = xzCheckHash(x.check)
+	x.inBlock = true
+
+	return nil
+}
+
+// readBlockEnd reads the padding and the check of a block.
+func (x *xzReader) readBlockEnd() error {
+	for x.r.n%4 != 0 {
+		c, err := x.r.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if c != 0 {
+			return errXZCorrupt
+		}
+	}
+
+	sum := make([]byte, xzCheckSize(x.check))
+	if _, err := x.r.Read(sum); err != nil {
+		return err
+	}
+	if x.hash != nil && !bytes.Equal(sum, x.hash.Sum(nil)) {
+		return errors.New("xz: checksum mismatch")
+	}
+
+	return nil
+}
+
+// readIndex skips the index and the footer of a stream, then looks for
+// another stream after the padding.
+func (x *xzReader) readIndex() error {
+	records, err := binary.ReadUvarint(x.r)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	for i := uint64(0); i < 2*records; i++ {
+		if _, err := binary.ReadUvarint(x.r); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	for x.r.n%4 != 0 {
+		if _, err := x.r.ReadByte(); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+
+	// the CRC32 of the index and the footer
+	var footer [16]byte
+	if _, err := x.r.Read(footer[:]); err != nil {
+		return err
+	}
+	if string(footer[14:]) != xzFooterMagic {
+		return errXZCorrupt
+	}
+
+	for {
+		b, err := x.r.br.Peek(4)
+		if err == io.EOF || (err != nil && len(b) == 0) {
+			x.done = true
+			return nil
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if string(b) != "\x00\x00\x00\x00" {
+			break
+		}
+		x.r.br.Discard(4)
+		x.r.n += 4
+	}
+
+	return x.readStreamHeader()
+}
+
+func xzCheckSize(check byte) int {
+	switch {
+	case check == 0:
+		return 0
+	case check <= 3:
+		return 4
+	case check <= 6:
+		return 8
+	case check <= 9:
+		return 16
+	case check <= 12:
+		return 32
+	}
+
+	return 64
+}
+
+// xzCheckHash returns the hash of a check type, or nil for types that
+// aren't verified.
+func xzCheckHash(check byte) hash.Hash {
+	switch check {
+	case 0x01:
+		return &crc32LE{crc32.NewIEEE()}
+	case 0x04:
+		return &crc64LE{crc64.New(crc64Table)}
+	case 0x0a:
+		return sha256.New()
+	}
+
+	return nil
+}
+
+// crc32LE and crc64LE sum to little endian bytes, as they're stored by xz.
+type crc32LE struct{ hash.Hash32 }
+
+func (c *crc32LE) Sum(b []byte) []byte {
+	var s [4]byte
+	binary.LittleEndian.PutUint32(s[:], c.Sum32())
+	return append(b, s[:]...)
+}
+
+type crc64LE struct{ hash.Hash64 }
+
+func (c *crc64LE) Sum(b []byte) []byte {
+	var s [8]byte
+	binary.LittleEndian.PutUint64(s[:], c.Sum64())
+	return append(b, s[:]...)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+func lzma2DictSize(props byte) (int, error) {
+	if props > 40 {
+		return 0, errXZCorrupt
+	}
+
+	size := uint64(2|props&1) << (props/2 + 11)
+	if props == 40 {
+		size = 1<<32 - 1
+	}
+	if size > xzMaxDictSize {
+		return 0, errors.New("xz: dictionary too large")
+	}
+
+	return int(size), nil
+}
+
+// lzma2Decoder decodes the chunks of LZMA2 data. The dictionary is the
+// output itself, trimmed to the last dictSize bytes between chunks.
+type lzma2Decoder struct {
+	dictSize int
+	dict     []byte
+	pos      int
+
+	lc, lp, pb int
+	needProps  bool
+	needReset  bool
+
+	rc    rangeDecoder
+	state int
+	reps  [4]int
+
+	isMatch    [lzmaStates * lzmaPosStatesMax]uint16
+	isRep      [lzmaStates]uint16
+	isRepG0    [lzmaStates]uint16
+	isRepG1    [lzmaStates]uint16
+	isRepG2    [lzmaStates]uint16
+	isRep0Long [lzmaStates * lzmaPosStatesMax]uint16
+	distSlot   [lzmaDistStates][lzmaDistSlots]uint16
+	distSpec   [1 + lzmaFullDists - lzmaDistModelEnd]uint16
+	align      [1 << lzmaAlignBits]uint16
+	matchLen   lzmaLenDecoder
+	repLen     lzmaLenDecoder
+	literal    []uint16
+}
+
+type lzmaLenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [lzmaPosStatesMax][lzmaLenLow]uint16
+	mid     [lzmaPosStatesMax][lzmaLenMid]uint16
+	high    [lzmaLenHigh]uint16
+}
+
+func newLZMA2Decoder(dictSize int) *lzma2Decoder {
+	return &lzma2Decoder{dictSize: dictSize, needProps: true, needReset: true}
+}
+
+// decodeChunk decodes the next chunk from r and returns its output, or
+// io.EOF at the end of the data.
+func (d *lzma2Decoder) decodeChunk(r io.ByteReader) ([]byte, error) {
+	control, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if control == 0x00 {
+		return nil, io.EOF
+	}
+
+	// keep no more of the output than the dictionary needs, trimming it
+	// once in a while
+	if len(d.dict) > 2*d.dictSize {
+		n := copy(d.dict, d.dict[len(d.dict)-d.dictSize:])
+		d.dict = d.dict[:n]
+	}
+	start := len(d.dict)
+
+	if control == 0x01 || control >= 0xe0 {
+		d.dict, d.pos, start = d.dict[:0], 0, 0
+		d.needReset = false
+	} else if d.needReset {
+		return nil, errXZCorrupt
+	}
+
+	if control < 0x80 {
+		if control > 0x02 {
+			return nil, errXZCorrupt
+		}
+		size, err := readUint16BE(r)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i <= size; i++ {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			d.dict = append(d.dict, c)
+		}
+		d.pos += size + 1
+
+		return d.dict[start:], nil
+	}
+
+	hi := int(control & 0x1f)
+	lo, err := readUint16BE(r)
+	if err != nil {
+		return nil, err
+	}
+	unpacked := hi<<16 + lo + 1
+	packed, err := readUint16BE(r)
+	if err != nil {
+		return nil, err
+	}
+	packed++
+
+	reset := (control >> 5) & 0x03
+	if reset >= 2 {
+		props, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if err := d.setProps(props); err != nil {
+			return nil, err
+		}
+	} else if d.needProps {
+		return nil, errXZCorrupt
+	}
+	if reset >= 1 {
+		d.resetState()
+	}
+
+	data := make([]byte, packed)
+	for i := range data {
+		if data[i], err = r.ReadByte(); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	if err := d.rc.init(data); err != nil {
+		return nil, err
+	}
+	if err := d.decode(start + unpacked); err != nil {
+		return nil, err
+	}
+
+	return d.dict[start:], nil
+}
+
+func readUint16BE(r io.ByteReader) (int, error) {
+	hi, err := r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	lo, err := r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	return int(hi)<<8 | int(lo), nil
+}
+
+func (d *lzma2Decoder) setProps(props byte) error {
+	if props >= 9*5*5 {
+		return errXZCorrupt
+	}
+	d.lc = int(props % 9)
+	props /= 9
+	d.lp = int(props % 5)
+	d.pb = int(props / 5)
+	if d.lc+d.lp > 4 {
+		return errXZCorrupt
+	}
+	d.needProps = false
+
+	return nil
+}
+
+func (d *lzma2Decoder) resetState() {
+	d.state = 0
+	d.reps = [4]int{}
+
+	initProbs(d.isMatch[:])
+	initProbs(d.isRep[:])
+	initProbs(d.isRepG0[:])
+	initProbs(d.isRepG1[:])
+	initProbs(d.isRepG2[:])
+	initProbs(d.isRep0Long[:])
+	for i := range d.distSlot {
+		initProbs(d.distSlot[i][:])
+	}
+	initProbs(d.distSpec[:])
+	initProbs(d.align[:])
+	d.matchLen.reset()
+	d.repLen.reset()
+
+	n := 0x300 << uint(d.lc+d.lp)
+	if cap(d.literal) < n {
+		d.literal = make([]uint16, n)
+	}
+	d.literal = d.literal[:n]
+	initProbs(d.literal)
+}
+
+func initProbs(probs []uint16) {
+	for i := range probs {
+		probs[i] = lzmaProbInit
+	}
+}
+
+func (l *lzmaLenDecoder) reset() {
+	l.choice, l.choice2 = lzmaProbInit, lzmaProbInit
+	for i := range l.low {
+		initProbs(l.low[i][:])
+		initProbs(l.mid[i][:])
+	}
+	initProbs(l.high[:])
+}
+
+func (l *lzmaLenDecoder) decode(rc *rangeDecoder, posState int) int {
+	if rc.bit(&l.choice) == 0 {
+		return rc.bitTree(l.low[posState][:], 3)
+	}
+	if rc.bit(&l.choice2) == 0 {
+		return lzmaLenLow + rc.bitTree(l.mid[posState][:], 3)
+	}
+
+	return lzmaLenLow + lzmaLenMid + rc.bitTree(l.high[:], 8)
+}
+
+// decode decodes LZMA data until the dictionary holds end bytes.
+func (d *lzma2Decoder) decode(end int) error {
+	rc := &d.rc
+	pbMask := 1<<uint(d.pb) - 1
+	lpMask := 1<<uint(d.lp) - 1
+
+	for len(d.dict) < end {
+		posState := d.pos & pbMask
+		if rc.bit(&d.isMatch[d.state*lzmaPosStatesMax+posState]) == 0 {
+			d.decodeLiteral(lpMask)
+			continue
+		}
+
+		var length int
+		if rc.bit(&d.isRep[d.state]) == 0 {
+			length = d.matchLen.decode(rc, posState)
+			if d.state < 7 {
+				d.state = 7
+			} else {
+				d.state = 10
+			}
+			dist := d.decodeDistance(length)
+			if dist == 0xffffffff {
+				// LZMA2 has no end markers
+				return errXZCorrupt
+			}
+			d.reps = [4]int{dist, d.reps[0], d.reps[1], d.reps[2]}
+		} else {
+			if rc.bit(&d.isRepG0[d.state]) == 0 {
+				if rc.bit(&d.isRep0Long[d.state*lzmaPosStatesMax+posState]) == 0 {
+					if d.state < 7 {
+						d.state = 9
+					} else {
+						d.state = 11
+					}
+					if err := d.copyMatch(d.reps[0], 1, end); err != nil {
+						return err
+					}
+					continue
+				}
+			} else {
+				var dist int
+				if rc.bit(&d.isRepG1[d.state]) == 0 {
+					dist = d.reps[1]
+				} else {
+					if rc.bit(&d.isRepG2[d.state]) == 0 {
+						dist = d.reps[2]
+					} else {
+						dist = d.reps[3]
+						d.reps[3] = d.reps[2]
+					}
+					d.reps[2] = d.reps[1]
+				}
+				d.reps[1] = d.reps[0]
+				d.reps[0] = dist
+			}
+			length = d.repLen.decode(rc, posState)
+			if d.state < 7 {
+				d.state = 8
+			} else {
+				d.state = 11
+			}
+		}
+
+		if err := d.copyMatch(d.reps[0], length+lzmaMatchMinLen, end); err != nil {
+			return err
+		}
+	}
+
+	return rc.err
+}
+
+func (d *lzma2Decoder) decodeLiteral(lpMask int) {
+	prev := 0
+	if len(d.dict) > 0 {
+		prev = int(d.dict[len(d.dict)-1])
+	}
+	base := 0x300 * ((d.pos&lpMask)<<uint(d.lc) + prev>>uint(8-d.lc))
+	probs := d.literal[base : base+0x300]
+
+	symbol := 1
+	if d.state >= 7 {
+		// after a match, the byte at the distance of the match guides
+		// the decoding until it differs
+		match := 0
+		if i := len(d.dict) - 1 - d.reps[0]; i >= 0 {
+			match = int(d.dict[i])
+		}
+		for symbol < 0x100 {
+			matchBit := (match >> 7) & 1
+			match <<= 1
+			bit := d.rc.bit(&probs[(1+matchBit)<<8+symbol])
+			symbol = symbol<<1 | bit
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = symbol<<1 | d.rc.bit(&probs[symbol])
+	}
+
+	d.dict = append(d.dict, byte(symbol))
+	d.pos++
+
+	switch {
+	case d.state < 4:
+		d.state = 0
+	case d.state < 10:
+		d.state -= 3
+	default:
+		d.state -= 6
+	}
+}
+
+func (d *lzma2Decoder) decodeDistance(length int) int {
+	lenState := length
+	if lenState > lzmaDistStates-1 {
+		lenState = lzmaDistStates - 1
+	}
+
+	slot := d.rc.bitTree(d.distSlot[lenState][:], 6)
+	if slot < 4 {
+		return slot
+	}
+
+	bits := uint(slot>>1) - 1
+	dist := (2 | slot&1) << bits
+	if slot < lzmaDistModelEnd {
+		return dist + d.rc.reverseBitTree(d.distSpec[dist-slot:], bits)
+	}
+
+	dist += d.rc.direct(bits-lzmaAlignBits) << lzmaAlignBits
+
+	return dist + d.rc.reverseBitTree(d.align[:], lzmaAlignBits)
+}
+
+func (d *lzma2Decoder) copyMatch(dist, length, end int) error {
+	if dist >= len(d.dict) || dist >= d.dictSize || len(d.dict)+length > end {
+		return errXZCorrupt
+	}
+
+	from := len(d.dict) - 1 - dist
+	for i := 0; i < length; i++ {
+		d.dict = append(d.dict, d.dict[from+i])
+	}
+	d.pos += length
+
+	return nil
+}
+
+// rangeDecoder decodes the bits of a chunk of LZMA data. Reading past the
+// end of the chunk sets err.
+type rangeDecoder struct {
+	data  []byte
+	rng   uint32
+	code  uint32
+	err   error
+	index int
+}
+
+func (rc *rangeDecoder) init(data []byte) error {
+	if len(data) < 5 || data[0] != 0 {
+		return errXZCorrupt
+	}
+
+	rc.data = data
+	rc.rng = 0xffffffff
+	rc.code = binary.BigEndian.Uint32(data[1:5])
+	rc.index = 5
+	rc.err = nil
+
+	return nil
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng >= 1<<24 {
+		return
+	}
+
+	rc.rng <<= 8
+	var c byte
+	if rc.index < len(rc.data) {
+		c = rc.data[rc.index]
+	} else {
+		rc.err = errXZCorrupt
+	}
+	rc.index++
+	rc.code = rc.code<<8 | uint32(c)
+}
+
+func (rc *rangeDecoder) bit(prob *uint16) int {
+	rc.normalize()
+
+	bound := (rc.rng >> 11) * uint32(*prob)
+	if rc.code < bound {
+		rc.rng = bound
+		*prob += (1<<11 - *prob) >> 5
+		return 0
+	}
+
+	rc.rng -= bound
+	rc.code -= bound
+	*prob -= *prob >> 5
+
+	return 1
+}
+
+func (rc *rangeDecoder) bitTree(probs []uint16, bits uint) int {
+	m := 1
+	for i := uint(0); i < bits; i++ {
+		m = m<<1 | rc.bit(&probs[m])
+	}
+
+	return m - 1<<bits
+}
+
+func (rc *rangeDecoder) reverseBitTree(probs []uint16, bits uint) int {
+	m, symbol := 1, 0
+	for i := uint(0); i < bits; i++ {
+		bit := rc.bit(&probs[m])
+		m = m<<1 | bit
+		symbol |= bit << i
+	}
+
+	return symbol
+}
+
+func (rc *rangeDecoder) direct(bits uint) int {
+	n := 0
+	for i := uint(0); i < bits; i++ {
+		rc.normalize()
+		rc.rng >>= 1
+		bit := 0
+		if rc.code >= rc.rng {
+			rc.code -= rc.rng
+			bit = 1
+		}
+		n = n<<1 | bit
+	}
+
+	return n
+}
@@ -0,0 +1,1027 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// The zstd reader decodes Zstandard frames as specified by RFC 8878,
+// following the structure of the educational decoder of the reference
+// implementation. Frames that need a dictionary aren't supported.
+
+const (
+	zstdMagic          = 0xfd2fb528
+	zstdSkippableMask  = 0xfffffff0
+	zstdSkippableMagic = 0x184d2a50
+	zstdMaxBlockSize   = 1 << 17
+	zstdMaxWindowSize  = 1 << 27
+
+	zstdMaxLitLenCode   = 35
+	zstdMaxMatchLenCode = 52
+	zstdMaxOffsetCode   = 31
+	huffmanMaxBits      = 11
+)
+
+var errZstdCorrupt = errors.New("zstd: corrupt data")
+
+var (
+	zstdLitLenBase = []int{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	zstdLitLenBits = []uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	zstdMatchLenBase = []int{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	zstdMatchLenBits = []uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+
+	// the predefined distributions of the codes
+	zstdLitLenTable = newFSETable([]int{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}, 6)
+	zstdMatchLenTable = newFSETable([]int{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}, 6)
+	zstdOffsetTable = newFSETable([]int{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}, 5)
+)
+
+// zstdReader decompresses the frames of a .zst file one block at a time.
+type zstdReader struct {
+	br *bufio.Reader
+
+	inFrame  bool
+	done     bool
+	window   int
+	checksum bool
+	hash     xxh64
+
+	// hist is the output of the frame so far, trimmed to the window
+	// between blocks
+	hist []byte
+	out  []byte
+
+	// what a block can take over from the previous ones
+	huffman  *huffmanTable
+	litLen   *fseTable
+	offset   *fseTable
+	matchLen *fseTable
+	reps     [3]int
+}
+
+func newZstdReader(r io.Reader) (io.Reader, error) {
+	z := &zstdReader{br: bufio.NewReader(r)}
+	if err := z.readFrameHeader(); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	return z, nil
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.done {
+			return 0, io.EOF
+		}
+
+		var err error
+		if z.inFrame {
+			err = z.readBlock()
+		} else {
+			err = z.readFrameHeader()
+			if err == io.EOF {
+				z.done = true
+				err = nil
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+
+	return n, nil
+}
+
+// readFrameHeader skips skippable frames and reads the header of the next
+// frame. It returns io.EOF if there's none.
+func (z *zstdReader) readFrameHeader() error {
+	var magic uint32
+	for {
+		var b [4]byte
+		if _, err := io.ReadFull(z.br, b[:]); err != nil {
+			return err
+		}
+		magic = binary.LittleEndian.Uint32(b[:])
+		if magic&zstdSkippableMask != zstdSkippableMagic {
+			break
+		}
+
+		if _, err := io.ReadFull(z.br, b[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		size := int64(binary.LittleEndian.Uint32(b[:]))
+		if _, err := io.CopyN(ioutil.Discard, z.br, size); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	if magic != zstdMagic {
+		return errors.New("zstd: invalid header")
+	}
+
+	fhd, err := z.br.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if fhd&0x08 != 0 {
+		return errZstdCorrupt
+	}
+	single := fhd&0x20 != 0
+	z.checksum = fhd&0x04 != 0
+
+	window := 0
+	if !single {
+		wd, err := z.br.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		base := 1 << (10 + uint(wd>>3))
+		window = base + base/8*int(wd&7)
+	}
+
+	dictID, err := z.readLE([]int{0, 1, 2, 4}[fhd&3])
+	if err != nil {
+		return err
+	}
+	if dictID != 0 {
+		return errors.New("zstd: dictionaries aren't supported")
+	}
+
+	fcsFlag := fhd >> 6
+	fcsLen := []int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && single {
+		fcsLen = 1
+	}
+	fcs, err := z.readLE(fcsLen)
+	if err != nil {
+		return err
+	}
+	if fcsFlag == 1 {
+		fcs += 256
+	}
+	if single {
+		window = int(fcs)
+		if fcs > zstdMaxWindowSize {
+			window = zstdMaxWindowSize + 1
+		}
+	}
+	if window > zstdMaxWindowSize {
+		return errors.New("zstd: window too large")
+	}
+
+	z.window = window
+	z.inFrame = true
+	z.hist = z.hist[:0]
+	z.hash.Reset()
+	z.huffman, z.litLen, z.offset, z.matchLen = nil, nil, nil, nil
+	z.reps = [3]int{1, 4, 8}
+
+	return nil
+}
+
+func (z *zstdReader) readLE(n int) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(z.br, b[:n]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+func (z *zstdReader) readBlock() error {
+	h, err := z.readLE(3)
+	if err != nil {
+		return err
+	}
+	last := h&1 != 0
+	size := int(h >> 3)
+
+	maxSize := zstdMaxBlockSize
+	if z.window < maxSize {
+		maxSize = z.window
+	}
+	if size > maxSize {
+		return errZstdCorrupt
+	}
+
+	// keep no more of the output than the window, trimming it once in a
+	// while
+	if len(z.hist) > 2*z.window+zstdMaxBlockSize {
+		n := copy(z.hist, z.hist[len(z.hist)-z.window:])
+		z.hist = z.hist[:n]
+	}
+	start := len(z.hist)
+
+	switch h >> 1 & 3 {
+	case 0:
+		data := make([]byte, size)
+		if _, err := io.ReadFull(z.br, data); err != nil {
+			return unexpectedEOF(err)
+		}
+		z.hist = append(z.hist, data...)
+	case 1:
+		c, err := z.br.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		for i := 0; i < size; i++ {
+			z.hist = append(z.hist, c)
+		}
+	case 2:
+		data := make([]byte, size)
+		if _, err := io.ReadFull(z.br, data); err != nil {
+			return unexpectedEOF(err)
+		}
+		if err := z.decodeBlock(data); err != nil {
+			return err
+		}
+	default:
+		return errZstdCorrupt
+	}
+
+	z.out = z.hist[start:]
+	z.hash.Write(z.out)
+
+	if last {
+		z.inFrame = false
+		if z.checksum {
+			sum, err := z.readLE(4)
+			if err != nil {
+				return err
+			}
+			if uint32(sum) != uint32(z.hash.Sum64()) {
+				return errors.New("zstd: checksum mismatch")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (z *zstdReader) decodeBlock(data []byte) error {
+	lits, n, err := z.decodeLiterals(data)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+
+	if len(data) == 0 {
+		return errZstdCorrupt
+	}
+	var nseq int
+	switch b0 := int(data[0]); {
+	case b0 < 128:
+		nseq, n = b0, 1
+	case b0 < 255:
+		if len(data) < 2 {
+			return errZstdCorrupt
+		}
+		nseq, n = (b0-128)<<8+int(data[1]), 2
+	default:
+		if len(data) < 3 {
+			return errZstdCorrupt
+		}
+		nseq, n = int(data[1])+int(data[2])<<8+0x7f00, 3
+	}
+	data = data[n:]
+
+	if nseq == 0 {
+		z.hist = append(z.hist, lits...)
+		return nil
+	}
+
+	if len(data) == 0 {
+		return errZstdCorrupt
+	}
+	modes := data[0]
+	if modes&3 != 0 {
+		return errZstdCorrupt
+	}
+	data = data[1:]
+
+	for _, t := range []struct {
+		table      **fseTable
+		predefined *fseTable
+		mode       byte
+		maxSymbol  int
+		maxLog     uint
+	}{
+		{&z.litLen, zstdLitLenTable, modes >> 6, zstdMaxLitLenCode, 9},
+		{&z.offset, zstdOffsetTable, modes >> 4 & 3, zstdMaxOffsetCode, 8},
+		{&z.matchLen, zstdMatchLenTable, modes >> 2 & 3, zstdMaxMatchLenCode, 9},
+	} {
+		switch t.mode {
+		case 0:
+			*t.table = t.predefined
+		case 1:
+			if len(data) == 0 || int(data[0]) > t.maxSymbol {
+				return errZstdCorrupt
+			}
+			*t.table = &fseTable{symbols: []byte{data[0]}, bits: []uint{0}, base: []int{0}}
+			data = data[1:]
+		case 2:
+			table, n, err := readFSETable(data, t.maxSymbol, t.maxLog)
+			if err != nil {
+				return err
+			}
+			*t.table = table
+			data = data[n:]
+		case 3:
+			if *t.table == nil {
+				return errZstdCorrupt
+			}
+		}
+	}
+
+	return z.execSequences(data, nseq, lits)
+}
+
+// execSequences decodes the sequences of a block and copies the literals
+// and matches they stand for to the output.
+func (z *zstdReader) execSequences(data []byte, nseq int, lits []byte) error {
+	br, err := newBackwardBits(data)
+	if err != nil {
+		return err
+	}
+
+	ll, of, ml := z.litLen, z.offset, z.matchLen
+	llState := int(br.read(ll.log))
+	ofState := int(br.read(of.log))
+	mlState := int(br.read(ml.log))
+
+	for i := 0; i < nseq; i++ {
+		llCode, ofCode, mlCode := ll.symbols[llState], of.symbols[ofState], ml.symbols[mlState]
+		if llCode > zstdMaxLitLenCode || mlCode > zstdMaxMatchLenCode || ofCode > zstdMaxOffsetCode {
+			return errZstdCorrupt
+		}
+
+		offset := 1<<ofCode + int(br.read(uint(ofCode)))
+		matchLen := zstdMatchLenBase[mlCode] + int(br.read(zstdMatchLenBits[mlCode]))
+		litLen := zstdLitLenBase[llCode] + int(br.read(zstdLitLenBits[llCode]))
+
+		if i < nseq-1 {
+			llState = ll.next(llState, br)
+			mlState = ml.next(mlState, br)
+			ofState = of.next(ofState, br)
+		}
+
+		if litLen > len(lits) {
+			return errZstdCorrupt
+		}
+		z.hist = append(z.hist, lits[:litLen]...)
+		lits = lits[litLen:]
+
+		dist := z.matchOffset(offset, litLen)
+		if dist <= 0 || dist > len(z.hist) || dist > z.window {
+			return errZstdCorrupt
+		}
+		from := len(z.hist) - dist
+		for j := 0; j < matchLen; j++ {
+			z.hist = append(z.hist, z.hist[from+j])
+		}
+	}
+	if br.pos != 0 {
+		return errZstdCorrupt
+	}
+
+	z.hist = append(z.hist, lits...)
+
+	return nil
+}
+
+// matchOffset returns the distance of a match from the value of its
+// offset, which stands for one of the repeated offsets if it's up to 3.
+func (z *zstdReader) matchOffset(value, litLen int) int {
+	if value > 3 {
+		offset := value - 3
+		z.reps = [3]int{offset, z.reps[0], z.reps[1]}
+		return offset
+	}
+
+	i := value - 1
+	if litLen == 0 {
+		i++
+	}
+	if i == 0 {
+		return z.reps[0]
+	}
+
+	var offset int
+	if i < 3 {
+		offset = z.reps[i]
+	} else {
+		offset = z.reps[0] - 1
+	}
+	if i > 1 {
+		z.reps[2] = z.reps[1]
+	}
+	z.reps[1] = z.reps[0]
+	z.reps[0] = offset
+
+	return offset
+}
+
+// decodeLiterals decodes the literals section at the start of a block
+// and returns the literals and the size of the section.
+func (z *zstdReader) decodeLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errZstdCorrupt
+	}
+
+	b0 := int(data[0])
+	format := b0 >> 2 & 3
+	if b0&3 < 2 {
+		var size, n int
+		switch format {
+		case 0, 2:
+			size, n = b0>>3, 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, errZstdCorrupt
+			}
+			size, n = b0>>4+int(data[1])<<4, 2
+		case 3:
+			if len(data) < 3 {
+				return nil, 0, errZstdCorrupt
+			}
+			size, n = b0>>4+int(data[1])<<4+int(data[2])<<12, 3
+		}
+
+		if b0&3 == 0 {
+			if len(data) < n+size {
+				return nil, 0, errZstdCorrupt
+			}
+			return data[n : n+size], n + size, nil
+		}
+
+		if len(data) < n+1 || size > zstdMaxBlockSize {
+			return nil, 0, errZstdCorrupt
+		}
+		lits := make([]byte, size)
+		for i := range lits {
+			lits[i] = data[n]
+		}
+		return lits, n + 1, nil
+	}
+
+	streams, n := 4, 0
+	var regen, comp int
+	switch format {
+	case 0, 1:
+		if format == 0 {
+			streams = 1
+		}
+		if len(data) < 3 {
+			return nil, 0, errZstdCorrupt
+		}
+		c := b0 | int(data[1])<<8 | int(data[2])<<16
+		regen, comp, n = c>>4&0x3ff, c>>14&0x3ff, 3
+	case 2:
+		if len(data) < 4 {
+			return nil, 0, errZstdCorrupt
+		}
+		c := int(binary.LittleEndian.Uint32(data))
+		regen, comp, n = c>>4&0x3fff, c>>18&0x3fff, 4
+	case 3:
+		if len(data) < 5 {
+			return nil, 0, errZstdCorrupt
+		}
+		c := int(binary.LittleEndian.Uint32(data)) | int(data[4])<<32
+		regen, comp, n = c>>4&0x3ffff, c>>22&0x3ffff, 5
+	}
+	if len(data) < n+comp || regen > zstdMaxBlockSize {
+		return nil, 0, errZstdCorrupt
+	}
+	src := data[n : n+comp]
+
+	if b0&3 == 2 {
+		t, m, err := readHuffmanTable(src)
+		if err != nil {
+			return nil, 0, err
+		}
+		z.huffman = t
+		src = src[m:]
+	} else if z.huffman == nil {
+		return nil, 0, errZstdCorrupt
+	}
+
+	lits := make([]byte, regen)
+	if streams == 1 {
+		if err := z.huffman.decode(src, lits); err != nil {
+			return nil, 0, err
+		}
+		return lits, n + comp, nil
+	}
+
+	if len(src) < 6 {
+		return nil, 0, errZstdCorrupt
+	}
+	sizes := []int{
+		int(binary.LittleEndian.Uint16(src)),
+		int(binary.LittleEndian.Uint16(src[2:])),
+		int(binary.LittleEndian.Uint16(src[4:])),
+	}
+	src = src[6:]
+	sizes = append(sizes, len(src)-sizes[0]-sizes[1]-sizes[2])
+
+	segment := (regen + 3) / 4
+	if sizes[3] < 0 || 3*segment > regen {
+		return nil, 0, errZstdCorrupt
+	}
+	out := lits
+	for i, size := range sizes {
+		m := segment
+		if i == 3 {
+			m = len(out)
+		}
+		if err := z.huffman.decode(src[:size], out[:m]); err != nil {
+			return nil, 0, err
+		}
+		src, out = src[size:], out[m:]
+	}
+
+	return lits, n + comp, nil
+}
+
+// backwardBits reads a bitstream from its end, where the highest bit of
+// the last byte marks the start. Bits before the beginning read as 0s.
+type backwardBits struct {
+	data []byte
+	pos  int
+}
+
+func newBackwardBits(data []byte) (*backwardBits, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errZstdCorrupt
+	}
+
+	return &backwardBits{data, (len(data)-1)*8 + highBit(uint64(data[len(data)-1]))}, nil
+}
+
+func (b *backwardBits) read(n uint) uint64 {
+	if n == 0 {
+		return 0
+	}
+	b.pos -= int(n)
+
+	return b.bitsAt(b.pos, n)
+}
+
+// bitsAt returns the n bits from bit pos on, with n up to 56.
+func (b *backwardBits) bitsAt(pos int, n uint) uint64 {
+	if pos < 0 {
+		if pos+int(n) <= 0 {
+			return 0
+		}
+		return b.bitsAt(0, uint(pos+int(n))) << uint(-pos)
+	}
+
+	i := pos >> 3
+	var v uint64
+	for j := 0; j < 8 && i+j < len(b.data); j++ {
+		v |= uint64(b.data[i+j]) << (8 * uint(j))
+	}
+
+	return v >> uint(pos&7) & (1<<n - 1)
+}
+
+// highBit returns the index of the highest bit set in v.
+func highBit(v uint64) int {
+	n := -1
+	for ; v != 0; v >>= 1 {
+		n++
+	}
+
+	return n
+}
+
+// fseTable is the decoding table of a finite state entropy code. A state
+// stands for a symbol, and the next state is base plus the next bits.
+type fseTable struct {
+	log     uint
+	symbols []byte
+	bits    []uint
+	base    []int
+}
+
+func (t *fseTable) next(state int, br *backwardBits) int {
+	return t.base[state] + int(br.read(t.bits[state]))
+}
+
+// newFSETable builds the decoding table of the normalized probabilities
+// probs, where -1 stands for less than 1.
+func newFSETable(probs []int, log uint) *fseTable {
+	size := 1 << log
+	t := &fseTable{
+		log:     log,
+		symbols: make([]byte, size),
+		bits:    make([]uint, size),
+		base:    make([]int, size),
+	}
+
+	next := make([]int, len(probs))
+	high := size
+	for s, p := range probs {
+		if p == -1 {
+			high--
+			t.symbols[high] = byte(s)
+			next[s] = 1
+		}
+	}
+
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for s, p := range probs {
+		if p <= 0 {
+			continue
+		}
+		next[s] = p
+		for i := 0; i < p; i++ {
+			t.symbols[pos] = byte(s)
+			for {
+				pos = (pos + step) & (size - 1)
+				if pos < high {
+					break
+				}
+			}
+		}
+	}
+
+	for i := 0; i < size; i++ {
+		s := t.symbols[i]
+		n := next[s]
+		next[s]++
+		t.bits[i] = log - uint(highBit(uint64(n)))
+		t.base[i] = n<<t.bits[i] - size
+	}
+
+	return t
+}
+
+// readFSETable reads the description of an FSE table from the start of
+// data and returns the table and the size of the description.
+func readFSETable(data []byte, maxSymbol int, maxLog uint) (*fseTable, int, error) {
+	fb := &forwardBits{data: data}
+	log := uint(fb.read(4)) + 5
+	if log > maxLog {
+		return nil, 0, errZstdCorrupt
+	}
+
+	var probs []int
+	remaining := 1 << log
+	for remaining > 0 && len(probs) <= maxSymbol {
+		bits := uint(highBit(uint64(remaining+1)) + 1)
+		v := int(fb.read(bits))
+		lowMask := 1<<(bits-1) - 1
+		threshold := 1<<bits - 1 - (remaining + 1)
+		if v&lowMask < threshold {
+			fb.pos--
+			v &= lowMask
+		} else if v > lowMask {
+			v -= threshold
+		}
+
+		p := v - 1
+		if p < 0 {
+			remaining += p
+		} else {
+			remaining -= p
+		}
+		probs = append(probs, p)
+
+		if p == 0 {
+			for {
+				repeat := int(fb.read(2))
+				for i := 0; i < repeat && len(probs) <= maxSymbol; i++ {
+					probs = append(probs, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+		}
+	}
+
+	n := (fb.pos + 7) / 8
+	if remaining != 0 || len(probs) > maxSymbol+1 || n > len(data) {
+		return nil, 0, errZstdCorrupt
+	}
+
+	return newFSETable(probs, log), n, nil
+}
+
+// forwardBits reads a bitstream from its start, from the lowest bit of
+// each byte up. Bits past the end read as 0s.
+type forwardBits struct {
+	data []byte
+	pos  int
+}
+
+func (f *forwardBits) read(n uint) uint64 {
+	var v uint64
+	for i := uint(0); i < n; i++ {
+		if j := f.pos >> 3; j < len(f.data) {
+			v |= uint64(f.data[j]>>uint(f.pos&7)&1) << i
+		}
+		f.pos++
+	}
+
+	return v
+}
+
+// huffmanTable is the decoding table of a Huffman code, indexed by the
+// next maxBits bits of a stream.
+type huffmanTable struct {
+	maxBits uint
+	symbols []byte
+	bits    []uint
+}
+
+// readHuffmanTable reads the description of a Huffman code from the
+// start of data and returns its table and the size of the description.
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errZstdCorrupt
+	}
+
+	var weights []byte
+	n := 0
+	if h := int(data[0]); h < 128 {
+		n = 1 + h
+		if len(data) < n {
+			return nil, 0, errZstdCorrupt
+		}
+		var err error
+		if weights, err = readHuffmanWeights(data[1:n]); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		weights = make([]byte, h-127)
+		n = 1 + (len(weights)+1)/2
+		if len(data) < n {
+			return nil, 0, errZstdCorrupt
+		}
+		for i := range weights {
+			w := data[1+i/2]
+			if i%2 == 0 {
+				w >>= 4
+			}
+			weights[i] = w & 0x0f
+		}
+	}
+
+	t, err := newHuffmanTable(weights)
+
+	return t, n, err
+}
+
+// readHuffmanWeights decodes weights compressed with FSE, which come with
+// two interleaved states.
+func readHuffmanWeights(data []byte) ([]byte, error) {
+	t, n, err := readFSETable(data, 255, 6)
+	if err != nil {
+		return nil, err
+	}
+	br, err := newBackwardBits(data[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	var weights []byte
+	states := [2]int{int(br.read(t.log)), int(br.read(t.log))}
+	for i := 0; ; i ^= 1 {
+		weights = append(weights, t.symbols[states[i]])
+		states[i] = t.next(states[i], br)
+		if br.pos < 0 {
+			weights = append(weights, t.symbols[states[i^1]])
+			break
+		}
+		if len(weights) > 255 {
+			return nil, errZstdCorrupt
+		}
+	}
+
+	return weights, nil
+}
+
+// newHuffmanTable builds the table of a code from the weights of its
+// symbols. The weight of the last symbol is implied.
+func newHuffmanTable(weights []byte) (*huffmanTable, error) {
+	if len(weights)+1 > 256 {
+		return nil, errZstdCorrupt
+	}
+
+	sum := 0
+	for _, w := range weights {
+		if w > huffmanMaxBits {
+			return nil, errZstdCorrupt
+		}
+		if w > 0 {
+			sum += 1 << (w - 1)
+		}
+	}
+	if sum == 0 {
+		return nil, errZstdCorrupt
+	}
+
+	maxBits := uint(highBit(uint64(sum)) + 1)
+	left := 1<<maxBits - sum
+	if left&(left-1) != 0 || maxBits > huffmanMaxBits {
+		return nil, errZstdCorrupt
+	}
+
+	bits := make([]uint, len(weights)+1)
+	for i, w := range weights {
+		if w > 0 {
+			bits[i] = maxBits + 1 - uint(w)
+		}
+	}
+	bits[len(weights)] = maxBits + 1 - uint(highBit(uint64(left))+1)
+
+	var counts [huffmanMaxBits + 1]int
+	for _, b := range bits {
+		counts[b]++
+	}
+
+	size := 1 << maxBits
+	t := &huffmanTable{maxBits: maxBits, symbols: make([]byte, size), bits: make([]uint, size)}
+
+	// codes are handed out from the longest to the shortest
+	var starts [huffmanMaxBits + 1]int
+	for b := maxBits; b >= 1; b-- {
+		starts[b-1] = starts[b] + counts[b]<<(maxBits-b)
+		for i := starts[b]; i < starts[b-1]; i++ {
+			t.bits[i] = b
+		}
+	}
+	if starts[0] != size {
+		return nil, errZstdCorrupt
+	}
+
+	for s, b := range bits {
+		if b == 0 {
+			continue
+		}
+		n := 1 << (maxBits - b)
+		for i := starts[b]; i < starts[b]+n; i++ {
+			t.symbols[i] = byte(s)
+		}
+		starts[b] += n
+	}
+
+	return t, nil
+}
+
+// decode decodes len(out) symbols from a stream.
+func (t *huffmanTable) decode(data []byte, out []byte) error {
+	br, err := newBackwardBits(data)
+	if err != nil {
+		return err
+	}
+
+	mask := 1<<t.maxBits - 1
+	state := int(br.read(t.maxBits))
+	for i := range out {
+		out[i] = t.symbols[state]
+		n := t.bits[state]
+		state = (state<<n | int(br.read(n))) & mask
+	}
+	if br.pos != -int(t.maxBits) {
+		return errZstdCorrupt
+	}
+
+	return nil
+}
+
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 is the XXH64 hash with a seed of 0, which zstd checks the content
+// of frames with.
+type xxh64 struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int
+}
+
+func (x *xxh64) Reset() {
+	var seed uint64
+	x.v = [4]uint64{seed + xxhPrime1 + xxhPrime2, seed + xxhPrime2, seed, seed - xxhPrime1}
+	x.total = 0
+	x.n = 0
+}
+
+func (x *xxh64) Write(b []byte) {
+	x.total += uint64(len(b))
+
+	if x.n > 0 {
+		m := copy(x.mem[x.n:], b)
+		x.n += m
+		b = b[m:]
+		if x.n < 32 {
+			return
+		}
+		x.stripe(x.mem[:])
+		x.n = 0
+	}
+
+	for ; len(b) >= 32; b = b[32:] {
+		x.stripe(b)
+	}
+	x.n = copy(x.mem[:], b)
+}
+
+func (x *xxh64) stripe(b []byte) {
+	for i := range x.v {
+		x.v[i] = xxhRound(x.v[i], binary.LittleEndian.Uint64(b[8*i:]))
+	}
+}
+
+func (x *xxh64) Sum64() uint64 {
+	var h uint64
+	if x.total >= 32 {
+		h = rotl64(x.v[0], 1) + rotl64(x.v[1], 7) + rotl64(x.v[2], 12) + rotl64(x.v[3], 18)
+		for _, v := range x.v {
+			h ^= xxhRound(0, v)
+			h = h*xxhPrime1 + xxhPrime4
+		}
+	} else {
+		h = x.v[2] + xxhPrime5
+	}
+	h += x.total
+
+	b := x.mem[:x.n]
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxhRound(0, binary.LittleEndian.Uint64(b))
+		h = rotl64(h, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxhPrime1
+		h = rotl64(h, 23)*xxhPrime2 + xxhPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxhPrime5
+		h = rotl64(h, 11) * xxhPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxhPrime2
+	h ^= h >> 29
+	h *= xxhPrime3
+	h ^= h >> 32
+
+	return h
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	acc = rotl64(acc, 31)
+
+	return acc * xxhPrime1
+}
+
+func rotl64(v uint64, n uint) uint64 {
+	return v<<n | v>>(64-n)
+}